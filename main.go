@@ -1,73 +1,162 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"probable-system/main.go/processing"
+	"probable-system/main.go/processing/validator"
 	"sync"
+	"time"
+	_ "time/tzdata"
 
 	"probable-system/main.go/server"
 	"probable-system/main.go/server/handlers"
+	"probable-system/main.go/server/services/transportation"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
+	gtfsPath := flag.String("gtfs", "processing/input", "GTFS static feed directory or .zip archive")
+	snapshotPath := flag.String("snapshot", "processing/snapshot/feed.gob", "binary feed snapshot used to skip parsing at startup, empty to disable")
+	feedsPath := flag.String("feeds", "", "JSON feed registry listing each agency feed, replaces -gtfs and -snapshot")
+	generate := flag.String("generate", "", "also write the default feed as Go source files to this directory")
+	watch := flag.Duration("watch", time.Minute, "how often to check the GTFS sources for a new feed, 0 to disable")
+	realtime := flag.Duration("realtime", 30*time.Second, "how often to poll the GTFS-RT feeds, 0 to fetch them on every request")
+	flag.Parse()
+
+	registry := transportation.DefaultFeedRegistry(*gtfsPath, *snapshotPath)
+	if *feedsPath != "" {
+		var err error
+		registry, err = transportation.LoadFeedRegistry(*feedsPath)
+		if err != nil {
+			fmt.Println("Error loading feed registry:", err)
+			os.Exit(1)
+		}
+	}
+	handlers.InitFeedRegistry(registry)
+
+	for _, config := range registry.Feeds {
+		feed, err := loadFeed(config.Static, config.Snapshot)
+		if err != nil {
+			continue
+		}
+		if missing := feed.MissingRequired(); len(missing) > 0 {
+			fmt.Printf("GTFS feed %s is missing required files: %v\n", config.ID, missing)
+		}
+		handlers.InitFeed(config.ID, feed)
+		if *generate != "" && config.ID == registry.Default {
+			generateSources(feed, *generate)
+		}
+	}
+	if *watch > 0 {
+		handlers.WatchFeeds(*watch)
+	}
+	if *realtime > 0 {
+		handlers.PollRealtimeFeeds(*realtime)
+	}
+
+	// Start the server
+	server.StartServer()
+}
+
+func loadFeed(path string, snapshotPath string) (*processing.Feed, error) {
+	fmt.Println("Loading GTFS feed from", path)
+	var feed *processing.Feed
+	var err error
+	if snapshotPath != "" {
+		feed, err = processing.LoadFeedCached(path, snapshotPath)
+	} else {
+		feed, err = processing.LoadFeed(path)
+	}
+	if err != nil {
+		fmt.Println("Error loading GTFS feed:", err)
+		var parseErrs *processing.ParseErrors
+		if errors.As(err, &parseErrs) {
+			for _, parseErr := range parseErrs.Errors {
+				fmt.Println("  ", parseErr)
+			}
+		}
+	}
+	return feed, err
+}
+
+// validate runs the feed validator from the command line:
+//
+//	probable-system validate [-gtfs path] [-json]
+//
+// It exits with status 1 when the feed has errors.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	gtfsPath := flags.String("gtfs", "processing/input", "GTFS static feed directory or .zip archive")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	feed, err := loadFeed(*gtfsPath, "")
+	if err != nil {
+		return 1
+	}
+	loc, err := feed.Location()
+	if err != nil {
+		loc = time.UTC
+	}
+
+	report := validator.Validate(feed, processing.Today(loc))
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.Print(os.Stdout)
+	}
+	if !report.Valid() {
+		return 1
+	}
+	return 0
+}
+
+func generateSources(feed *processing.Feed, outputDir string) {
+
 	var wg sync.WaitGroup
 	wg.Add(5)
 
 	go func() {
 		fmt.Println("Starting GenerateTripData...")
-		haveData := processing.GenerateTripData()
-		if haveData {
-			fmt.Println("Initializing Trip Map...")
-			handlers.InitTripsMap()
-		}
+		processing.GenerateTripData(feed.Trips, outputDir)
 		fmt.Println("Finished GenerateTripData")
 		wg.Done()
 	}()
 	go func() {
 		fmt.Println("Starting GenerateRouteData...")
-		haveData := processing.GenerateRouteData()
-		if haveData {
-			fmt.Println("Initializing Route Map...")
-			handlers.InitRouteMap()
-		}
+		processing.GenerateRouteData(feed.Routes, outputDir)
 		fmt.Println("Finished GenerateRouteData")
 		wg.Done()
 	}()
 	go func() {
 		fmt.Println("Starting GenerateShapesData...")
-		haveData := processing.GenerateShapesData()
-		if haveData {
-			fmt.Println("Initializing Shapes Map...")
-			handlers.InitShapesMap()
-		}
+		processing.GenerateShapesData(feed.Shapes, outputDir)
 		fmt.Println("Finished GenerateShapesData")
 		wg.Done()
 	}()
 	go func() {
 		fmt.Println("Starting GenerateStopTimesData...")
-		haveData := processing.GenerateStopTimesData()
-		if haveData {
-			fmt.Println("Initializing Stop Times Map...")
-			handlers.InitStopTimesMap()
-		}
+		processing.GenerateStopTimesData(feed.StopTimes, outputDir)
 		fmt.Println("Finished GenerateStopTimesData")
 		wg.Done()
 	}()
 	go func() {
 		fmt.Println("Starting GenerateStopsData...")
-		haveData := processing.GenerateStopsData()
-		if haveData {
-			fmt.Println("Initializing Stops Map...")
-			handlers.InitStopsMap()
-		}
+		processing.GenerateStopsData(feed.Stops, outputDir)
 		fmt.Println("Finished GenerateStopsData")
 		wg.Done()
 	}()
 
 	wg.Wait()
 	fmt.Println("All processing tasks completed.")
-
-	// Start the server
-	server.StartServer()
 }
@@ -0,0 +1,117 @@
+package processing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DateLayout is the YYYYMMDD format GTFS uses for service dates.
+const DateLayout = "20060102"
+
+const (
+	ServiceAdded   = 1
+	ServiceRemoved = 2
+)
+
+// ParseDate parses a GTFS YYYYMMDD date as midnight in loc.
+func ParseDate(date string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(DateLayout, date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYYMMDD", date)
+	}
+	return t, nil
+}
+
+// RunsOn reports whether the weekly pattern of the calendar includes weekday.
+func (c Calendar) RunsOn(weekday time.Weekday) bool {
+	days := [7]int{c.Sunday, c.Monday, c.Tuesday, c.Wednesday, c.Thursday, c.Friday, c.Saturday}
+	return days[weekday] == 1
+}
+
+// ServiceStatus is the state of one service ID on a date.
+type ServiceStatus struct {
+	ServiceID string `json:"service_id"`
+	Active    bool   `json:"active"`
+	Exception string `json:"exception,omitempty"` // added or removed by calendar_dates.txt
+}
+
+// ServiceCalendar resolves which service IDs run on a given date from
+// calendar.txt weekly patterns and calendar_dates.txt exceptions.
+type ServiceCalendar struct {
+	calendars  map[string]Calendar
+	exceptions map[string]map[string]int // date -> service ID -> exception type
+}
+
+func NewServiceCalendar(calendars []Calendar, calendarDates []CalendarDate) *ServiceCalendar {
+	c := &ServiceCalendar{
+		calendars:  make(map[string]Calendar, len(calendars)),
+		exceptions: make(map[string]map[string]int),
+	}
+	for _, calendar := range calendars {
+		c.calendars[calendar.ServiceID] = calendar
+	}
+	for _, calendarDate := range calendarDates {
+		if c.exceptions[calendarDate.Date] == nil {
+			c.exceptions[calendarDate.Date] = make(map[string]int)
+		}
+		c.exceptions[calendarDate.Date][calendarDate.ServiceID] = calendarDate.ExceptionType
+	}
+	return c
+}
+
+// IsActive reports whether serviceID runs on the calendar day of date.
+func (c *ServiceCalendar) IsActive(serviceID string, date time.Time) bool {
+	day := date.Format(DateLayout)
+	switch c.exceptions[day][serviceID] {
+	case ServiceAdded:
+		return true
+	case ServiceRemoved:
+		return false
+	}
+	calendar, found := c.calendars[serviceID]
+	if !found {
+		return false
+	}
+	return day >= calendar.StartDate && day <= calendar.EndDate && calendar.RunsOn(date.Weekday())
+}
+
+// ActiveServices returns the sorted service IDs running on date.
+func (c *ServiceCalendar) ActiveServices(date time.Time) []string {
+	var active []string
+	for _, status := range c.Statuses(date) {
+		if status.Active {
+			active = append(active, status.ServiceID)
+		}
+	}
+	return active
+}
+
+// Statuses returns every known service ID with whether it runs on date and
+// which exception, if any, decided it.
+func (c *ServiceCalendar) Statuses(date time.Time) []ServiceStatus {
+	day := date.Format(DateLayout)
+	serviceIDs := make(map[string]bool)
+	for serviceID := range c.calendars {
+		serviceIDs[serviceID] = true
+	}
+	for serviceID := range c.exceptions[day] {
+		serviceIDs[serviceID] = true
+	}
+
+	statuses := make([]ServiceStatus, 0, len(serviceIDs))
+	for serviceID := range serviceIDs {
+		status := ServiceStatus{ServiceID: serviceID, Active: c.IsActive(serviceID, date)}
+		switch c.exceptions[day][serviceID] {
+		case ServiceAdded:
+			status.Exception = "added"
+		case ServiceRemoved:
+			status.Exception = "removed"
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ServiceID < statuses[j].ServiceID
+	})
+	return statuses
+}
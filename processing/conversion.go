@@ -1,69 +1,34 @@
 package processing
 
 import (
-	"encoding/csv"
 	"fmt"
 	"os"
-	"strconv"
-	"strings"
+	"path/filepath"
 )
 
-const outputUrl = "/Users/peterbishop/Development/probable-system/processing/output/"
-const inputUrl = "/Users/peterbishop/Development/probable-system/processing/input/"
-
-func OpenFile(fileName string) ([][]string, error) {
-	file, err := os.Open(inputUrl + fileName)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil, err
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	reader.TrimLeadingSpace = true
-	records, err := reader.ReadAll()
-	if err != nil {
-		fmt.Println("Error reading CSV:", err)
-		return nil, err
+func tripFromRow(row *Row) Trip {
+	return Trip{
+		RouteID:              row.Required("route_id"),
+		ServiceID:            row.Required("service_id"),
+		TripID:               row.Required("trip_id"),
+		TripHeadsign:         row.String("trip_headsign"),
+		TripShortName:        row.String("trip_short_name"),
+		DirectionID:          row.Int("direction_id"),
+		BlockID:              row.String("block_id"),
+		ShapeID:              row.String("shape_id"),
+		WheelchairAccessible: row.Int("wheelchair_accessible"),
+		BikesAllowed:         row.Int("bikes_allowed"),
 	}
-
-	return records, nil
 }
 
-func GenerateTripData() bool {
-
-	if _, err := os.Stat(outputUrl + "stop_times.go"); err == nil {
-		fmt.Println("Trip File already exists, skipping generation.")
-		return true
-	}
+func GenerateTripData(trips []Trip, outputDir string) bool {
 
-	records, err := OpenFile("trips.txt")
-	if err != nil {
-		fmt.Println("Error opening file:", err)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
 		return false
 	}
-	var trips []Trip
-	for i, row := range records {
-		if i == 0 {
-			continue
-		}
-
-		var directionID int
-		fmt.Sscanf(row[4], "%d", &directionID)
-		blockID := strings.TrimSpace(row[5])
 
-		trips = append(trips, Trip{
-			RouteID:      row[0],
-			ServiceID:    row[1],
-			TripID:       row[2],
-			TripHeadsign: row[3],
-			DirectionID:  directionID,
-			BlockID:      blockID,
-			ShapeID:      row[6],
-		})
-	}
-
-	outputFile := fmt.Sprintf(outputUrl + "trips.go")
+	outputFile := filepath.Join(outputDir, "trips.go")
 	file, err := os.Create(outputFile)
 	if err != nil {
 		fmt.Println("Error creating Go file:", err)
@@ -75,53 +40,39 @@ func GenerateTripData() bool {
 	fmt.Fprintln(file, "import \"probable-system/main.go/processing\"")
 	fmt.Fprintln(file, "var Trips = []processing.Trip{")
 	for _, trip := range trips {
-		fmt.Fprintf(file, "\t{RouteID: \"%s\", ServiceID: \"%s\", TripID: \"%s\", TripHeadsign: \"%s\", DirectionID: %d, BlockID: \"%s\", ShapeID: \"%s\"},\n",
-			trip.RouteID, trip.ServiceID, trip.TripID, trip.TripHeadsign, trip.DirectionID, trip.BlockID, trip.ShapeID)
+		fmt.Fprintf(file, "\t%#v,\n", trip)
 	}
 	fmt.Fprintln(file, "}")
 	fmt.Println("Go file successfully saved to", outputFile)
 	return true
 }
 
-func GenerateRouteData() bool {
-
-	if _, err := os.Stat(outputUrl + "stop_times.go"); err == nil {
-		fmt.Println("Route File already exists, skipping generation.")
-		return true
-	}
-
-	records, err := OpenFile("routes.txt")
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return false
+func routeFromRow(row *Row) Route {
+	return Route{
+		RouteID:           row.Required("route_id"),
+		AgencyID:          row.String("agency_id"),
+		RouteShortName:    row.String("route_short_name"),
+		RouteLongName:     row.String("route_long_name"),
+		RouteDesc:         row.String("route_desc"),
+		RouteType:         row.RequiredInt("route_type"),
+		RouteURL:          row.String("route_url"),
+		RouteColor:        row.String("route_color"),
+		RouteTextColor:    row.String("route_text_color"),
+		RouteSortOrder:    row.Int("route_sort_order"),
+		ContinuousPickup:  row.IntOr("continuous_pickup", 1),
+		ContinuousDropOff: row.IntOr("continuous_drop_off", 1),
+		NetworkID:         row.String("network_id"),
 	}
+}
 
-	var routes []Route
-	for i, row := range records {
-		if i == 0 {
-			continue
-		}
-
-		routeType := strings.TrimSpace(row[5])
-		routeTypeInt := 0
-		if routeType == "3" {
-			routeTypeInt = 3
-		}
-		routes = append(routes, Route{
-			RouteID:        row[0],
-			AgencyID:       row[1],
-			RouteShortName: row[2],
-			RouteLongName:  row[3],
-			RouteDesc:      row[4],
-			RouteType:      routeTypeInt,
-			RouteURL:       row[6],
-			RouteColor:     row[7],
-			RouteTextColor: row[8],
-		})
+func GenerateRouteData(routes []Route, outputDir string) bool {
 
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return false
 	}
 
-	outputFile := fmt.Sprintf(outputUrl + "routes.go")
+	outputFile := filepath.Join(outputDir, "routes.go")
 	file, err := os.Create(outputFile)
 	if err != nil {
 		fmt.Println("Error creating Go file:", err)
@@ -133,60 +84,31 @@ func GenerateRouteData() bool {
 	fmt.Fprintln(file, "import \"probable-system/main.go/processing\"")
 	fmt.Fprintln(file, "var Routes = []processing.Route{")
 	for _, route := range routes {
-		fmt.Fprintf(file, "\t{RouteID: \"%s\", AgencyID: \"%s\", RouteShortName: \"%s\", RouteLongName: \"%s\", RouteDesc: \"%s\", RouteType: %d, RouteURL: \"%s\", RouteColor: \"%s\", RouteTextColor: \"%s\"},\n",
-			route.RouteID, route.AgencyID, route.RouteShortName, route.RouteLongName, route.RouteDesc, route.RouteType, route.RouteURL, route.RouteColor, route.RouteTextColor)
+		fmt.Fprintf(file, "\t%#v,\n", route)
 	}
 	fmt.Fprintln(file, "}")
 	fmt.Println("Go file successfully saved to", outputFile)
 	return true
 }
 
-func GenerateShapesData() bool {
-
-	if _, err := os.Stat(outputUrl + "stop_times.go"); err == nil {
-		fmt.Println("Shape File already exists, skipping generation.")
-		return true
-	}
-
-	records, err := OpenFile("shapes.txt")
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return false
+func shapeFromRow(row *Row) Shape {
+	return Shape{
+		ShapeID:           row.Required("shape_id"),
+		ShapePtLat:        row.RequiredFloat("shape_pt_lat"),
+		ShapePtLon:        row.RequiredFloat("shape_pt_lon"),
+		ShapePtSequence:   row.RequiredInt("shape_pt_sequence"),
+		ShapeDistTraveled: row.Float("shape_dist_traveled"),
 	}
+}
 
-	var shapes []Shape
-	for i, row := range records {
-		if i == 0 {
-			continue
-		}
-
-		shapeDistTraveled := strings.TrimSpace(row[4])
-		shapeDistTraveledFloat := 0.0
-		if shapeDistTraveled != "" {
-			fmt.Sscanf(shapeDistTraveled, "%f", &shapeDistTraveledFloat)
-		}
-		shapePtSequence := strings.TrimSpace(row[3])
-		shapePtSequenceInt := 0
-		if shapePtSequence != "" {
-			fmt.Sscanf(shapePtSequence, "%d", &shapePtSequenceInt)
-		}
-		shapes = append(shapes, Shape{
-			ShapeID: row[0],
-			ShapePtLat: func() float64 {
-				lat, _ := strconv.ParseFloat(row[1], 64)
-				return lat
-			}(),
-			ShapePtLon: func() float64 {
-				lon, _ := strconv.ParseFloat(row[2], 64)
-				return lon
-			}(),
-			ShapePtSequence:   shapePtSequenceInt,
-			ShapeDistTraveled: shapeDistTraveledFloat,
-		})
+func GenerateShapesData(shapes []Shape, outputDir string) bool {
 
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return false
 	}
 
-	outputFile := fmt.Sprintf(outputUrl + "shapes.go")
+	outputFile := filepath.Join(outputDir, "shapes.go")
 	file, err := os.Create(outputFile)
 	if err != nil {
 		fmt.Println("Error creating Go file:", err)
@@ -198,47 +120,38 @@ func GenerateShapesData() bool {
 	fmt.Fprintln(file, "import \"probable-system/main.go/processing\"")
 	fmt.Fprintln(file, "var Shapes = []processing.Shape{")
 	for _, shape := range shapes {
-		fmt.Fprintf(file, "\t{ShapeID: \"%s\", ShapePtLat: %f, ShapePtLon: %f, ShapePtSequence: %d, ShapeDistTraveled: %f},\n",
-			shape.ShapeID, shape.ShapePtLat, shape.ShapePtLon, shape.ShapePtSequence, shape.ShapeDistTraveled)
+		fmt.Fprintf(file, "\t%#v,\n", shape)
 	}
 	fmt.Fprintln(file, "}")
 	fmt.Println("Go file successfully saved to", outputFile)
 	return true
 }
 
-func GenerateStopTimesData() bool {
-
-	if _, err := os.Stat(outputUrl + "stop_times.go"); err == nil {
-		fmt.Println("StopTime File already exists, skipping generation.")
-		return true
+func stopTimeFromRow(row *Row) StopTime {
+	return StopTime{
+		TripID:            row.Required("trip_id"),
+		ArrivalTime:       row.Time("arrival_time"),
+		DepartureTime:     row.Time("departure_time"),
+		StopID:            row.Required("stop_id"),
+		StopSequence:      row.RequiredInt("stop_sequence"),
+		StopHeadsign:      row.String("stop_headsign"),
+		PickupType:        row.Int("pickup_type"),
+		DropOffType:       row.Int("drop_off_type"),
+		ContinuousPickup:  row.IntOr("continuous_pickup", 1),
+		ContinuousDropOff: row.IntOr("continuous_drop_off", 1),
+		ShapeDistTraveled: row.Float("shape_dist_traveled"),
+		Timepoint:         row.IntOr("timepoint", 1),
 	}
+}
 
-	records, err := OpenFile("stop_times.txt")
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return false
-	}
+func GenerateStopTimesData(stopTimes []StopTime, outputDir string) bool {
 
-	var stopTimes []StopTime
-	for i, row := range records {
-		if i == 0 {
-			continue
-		}
-		stopSequence, _ := strconv.Atoi(row[4])
-		pickupType, _ := strconv.Atoi(row[6])
-		dropOffType, _ := strconv.Atoi(row[7])
-		stopTimes = append(stopTimes, StopTime{
-			TripID:        row[0],
-			ArrivalTime:   row[1],
-			DepartureTime: row[2],
-			StopID:        row[3],
-			StopSequence:  stopSequence,
-			PickupType:    pickupType,
-			DropOffType:   dropOffType,
-		})
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return false
 	}
 
-	outputFile := fmt.Sprintf(outputUrl + "stop_times.go")
+	outputFile := filepath.Join(outputDir, "stop_times.go")
 	file, err := os.Create(outputFile)
 	if err != nil {
 		fmt.Println("Error creating Go file:", err)
@@ -250,45 +163,40 @@ func GenerateStopTimesData() bool {
 	fmt.Fprintln(file, "import \"probable-system/main.go/processing\"")
 	fmt.Fprintln(file, "var StopTime = []processing.StopTime{")
 	for _, stopTime := range stopTimes {
-		fmt.Fprintf(file, "\t{TripID: \"%s\", ArrivalTime: \"%s\", DepartureTime: \"%s\", StopID: \"%s\", StopSequence: %d, PickupType: %d, DropOffType: %d},\n",
-			stopTime.TripID, stopTime.ArrivalTime, stopTime.DepartureTime, stopTime.StopID, stopTime.StopSequence, stopTime.PickupType, stopTime.DropOffType)
+		fmt.Fprintf(file, "\t%#v,\n", stopTime)
 	}
 	fmt.Fprintln(file, "}")
 	fmt.Println("Go file successfully saved to", outputFile)
 	return true
 }
 
-func GenerateStopsData() bool {
-
-	if _, err := os.Stat(outputUrl + "stop_times.go"); err == nil {
-		fmt.Println("Stop File already exists, skipping generation.")
-		return true
+func stopFromRow(row *Row) Stop {
+	return Stop{
+		StopID:             row.Required("stop_id"),
+		StopCode:           row.String("stop_code"),
+		StopName:           row.String("stop_name"),
+		StopDesc:           row.String("stop_desc"),
+		StopLat:            row.Float("stop_lat"),
+		StopLon:            row.Float("stop_lon"),
+		ZoneID:             row.String("zone_id"),
+		StopURL:            row.String("stop_url"),
+		LocationType:       row.Int("location_type"),
+		ParentStation:      row.String("parent_station"),
+		StopTimezone:       row.String("stop_timezone"),
+		WheelchairBoarding: row.Int("wheelchair_boarding"),
+		LevelID:            row.String("level_id"),
+		PlatformCode:       row.String("platform_code"),
 	}
+}
 
-	records, err := OpenFile("stops.txt")
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return false
-	}
+func GenerateStopsData(stops []Stop, outputDir string) bool {
 
-	var stops []Stop
-	for i, row := range records {
-		if i == 0 {
-			continue
-		}
-		lat, _ := strconv.ParseFloat(row[4], 64)
-		lon, _ := strconv.ParseFloat(row[5], 64)
-		stops = append(stops, Stop{
-			StopID:   row[0],
-			StopCode: row[1],
-			StopName: row[2],
-			StopDesc: row[3],
-			StopLat:  lat,
-			StopLon:  lon,
-		})
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return false
 	}
 
-	outputFile := fmt.Sprintf(outputUrl + "stops.go")
+	outputFile := filepath.Join(outputDir, "stops.go")
 	file, err := os.Create(outputFile)
 	if err != nil {
 		fmt.Println("Error creating Go file:", err)
@@ -300,8 +208,7 @@ func GenerateStopsData() bool {
 	fmt.Fprintln(file, "import \"probable-system/main.go/processing\"")
 	fmt.Fprintln(file, "var Stop = []processing.Stop{")
 	for _, stop := range stops {
-		fmt.Fprintf(file, "\t{StopID: \"%s\", StopCode: \"%s\", StopName: \"%s\", StopDesc: \"%s\", StopLat: %f, StopLon: %f},\n",
-			stop.StopID, stop.StopCode, stop.StopName, stop.StopDesc, stop.StopLat, stop.StopLon)
+		fmt.Fprintf(file, "\t%#v,\n", stop)
 	}
 	fmt.Fprintln(file, "}")
 	fmt.Println("Go file successfully saved to", outputFile)
@@ -0,0 +1,217 @@
+package processing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxParseErrors caps how many row errors are kept per load so a badly
+// broken stop_times.txt does not hold millions of them in memory.
+const maxParseErrors = 100
+
+// ParseError describes a value in a GTFS file that could not be parsed.
+// Row is the line of the record in the file, counting the header as row 1.
+type ParseError struct {
+	File   string `json:"file"`
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Err    string `json:"error"`
+}
+
+func (e ParseError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("%s row %d: %s", e.File, e.Row, e.Err)
+	}
+	return fmt.Sprintf("%s row %d, column %s: %s", e.File, e.Row, e.Column, e.Err)
+}
+
+// ParseErrors is returned when one or more rows of a feed failed to parse.
+// Total counts every failure, including those beyond the kept sample.
+type ParseErrors struct {
+	Errors []ParseError `json:"errors"`
+	Total  int          `json:"total"`
+}
+
+func (e *ParseErrors) Error() string {
+	if e.Total == 1 {
+		return e.Errors[0].Error()
+	}
+	return fmt.Sprintf("%d parse errors, first: %s", e.Total, e.Errors[0].Error())
+}
+
+func (e *ParseErrors) add(err ParseError) {
+	e.Total++
+	if len(e.Errors) < maxParseErrors {
+		e.Errors = append(e.Errors, err)
+	}
+}
+
+// Row is one record of a GTFS file whose values are looked up by header
+// name. Conversion failures are collected on the row rather than returned
+// from every accessor.
+type Row struct {
+	file    string
+	line    int
+	columns map[string]int
+	values  []string
+	errs    []ParseError
+}
+
+func (r *Row) fail(column string, err string) {
+	r.errs = append(r.errs, ParseError{File: r.file, Row: r.line, Column: column, Err: err})
+}
+
+// Has reports whether the file has the column and the row a non-empty value for it.
+func (r *Row) Has(column string) bool {
+	return r.String(column) != ""
+}
+
+// String returns the trimmed value of column, or "" when the column is absent.
+func (r *Row) String(column string) string {
+	i, found := r.columns[column]
+	if !found || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+// Required returns the value of column and records an error if it is empty.
+func (r *Row) Required(column string) string {
+	value := r.String(column)
+	if value == "" {
+		r.fail(column, "required value is missing")
+	}
+	return value
+}
+
+// Int returns column as an integer, or 0 when it is empty.
+func (r *Row) Int(column string) int {
+	return r.IntOr(column, 0)
+}
+
+// IntOr returns column as an integer, or def when it is empty.
+func (r *Row) IntOr(column string, def int) int {
+	value := r.String(column)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.fail(column, fmt.Sprintf("invalid integer %q", value))
+		return def
+	}
+	return n
+}
+
+// RequiredInt returns column as an integer and records an error if it is empty.
+func (r *Row) RequiredInt(column string) int {
+	if r.Required(column) == "" {
+		return 0
+	}
+	return r.Int(column)
+}
+
+// Float returns column as a float, or 0 when it is empty.
+func (r *Row) Float(column string) float64 {
+	value := r.String(column)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.fail(column, fmt.Sprintf("invalid number %q", value))
+		return 0
+	}
+	return f
+}
+
+// RequiredFloat returns column as a float and records an error if it is empty.
+func (r *Row) RequiredFloat(column string) float64 {
+	if r.Required(column) == "" {
+		return 0
+	}
+	return r.Float(column)
+}
+
+// Time returns column as a GTFS time, or NoTime when it is empty.
+func (r *Row) Time(column string) Time {
+	value := r.String(column)
+	t, err := ParseTime(value)
+	if err != nil {
+		r.fail(column, err.Error())
+		return NoTime
+	}
+	return t
+}
+
+// RequiredTime returns column as a GTFS time and records an error if it is
+// empty.
+func (r *Row) RequiredTime(column string) Time {
+	if r.Required(column) == "" {
+		return NoTime
+	}
+	return r.Time(column)
+}
+
+// readRows streams the CSV in r, mapping columns by the header row and
+// passing each following row to fn. A file lacking one of the required
+// columns is skipped. Rows with unparseable values are added to errs; the
+// returned error is reserved for unreadable files.
+func readRows(file string, r io.Reader, required []string, errs *ParseErrors, fn func(row *Row)) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.TrimSpace(name)] = i
+	}
+	missingColumn := false
+	for _, name := range required {
+		if _, found := columns[name]; !found {
+			errs.add(ParseError{File: file, Row: 1, Column: name, Err: "required column is missing"})
+			missingColumn = true
+		}
+	}
+	if missingColumn {
+		return 0, nil
+	}
+
+	rows := 0
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var csvErr *csv.ParseError
+			if errors.As(err, &csvErr) {
+				errs.add(ParseError{File: file, Row: csvErr.Line, Err: csvErr.Err.Error()})
+				continue
+			}
+			return rows, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := &Row{file: file, line: line, columns: columns, values: values}
+		fn(row)
+		for _, rowErr := range row.errs {
+			errs.add(rowErr)
+		}
+		rows++
+	}
+}
@@ -1,25 +1,32 @@
 package processing
 
 type Trip struct {
-	RouteID      string `json:"route_id"`
-	ServiceID    string `json:"service_id"`
-	TripID       string `json:"trip_id"`
-	TripHeadsign string `json:"trip_headsign"`
-	DirectionID  int    `json:"direction_id"`
-	BlockID      string `json:"block_id"`
-	ShapeID      string `json:"shape_id"`
+	RouteID              string `json:"route_id"`
+	ServiceID            string `json:"service_id"`
+	TripID               string `json:"trip_id"`
+	TripHeadsign         string `json:"trip_headsign"`
+	TripShortName        string `json:"trip_short_name"`
+	DirectionID          int    `json:"direction_id"`
+	BlockID              string `json:"block_id"`
+	ShapeID              string `json:"shape_id"`
+	WheelchairAccessible int    `json:"wheelchair_accessible"`
+	BikesAllowed         int    `json:"bikes_allowed"`
 }
 
 type Route struct {
-	RouteID        string `json:"route_id"`
-	AgencyID       string `json:"agency_id"`
-	RouteShortName string `json:"route_short_name"`
-	RouteLongName  string `json:"route_long_name"`
-	RouteDesc      string `json:"route_desc"`
-	RouteType      int    `json:"route_type"`
-	RouteURL       string `json:"route_url"`
-	RouteColor     string `json:"route_color"`
-	RouteTextColor string `json:"route_text_color"`
+	RouteID           string `json:"route_id"`
+	AgencyID          string `json:"agency_id"`
+	RouteShortName    string `json:"route_short_name"`
+	RouteLongName     string `json:"route_long_name"`
+	RouteDesc         string `json:"route_desc"`
+	RouteType         int    `json:"route_type"`
+	RouteURL          string `json:"route_url"`
+	RouteColor        string `json:"route_color"`
+	RouteTextColor    string `json:"route_text_color"`
+	RouteSortOrder    int    `json:"route_sort_order"`
+	ContinuousPickup  int    `json:"continuous_pickup"`
+	ContinuousDropOff int    `json:"continuous_drop_off"`
+	NetworkID         string `json:"network_id,omitempty"`
 }
 
 type Shape struct {
@@ -32,13 +39,15 @@ type Shape struct {
 
 type StopTime struct {
 	TripID            string  `json:"trip_id"`
-	ArrivalTime       string  `json:"arrival_time"`
-	DepartureTime     string  `json:"departure_time"`
+	ArrivalTime       Time    `json:"arrival_time"`
+	DepartureTime     Time    `json:"departure_time"`
 	StopID            string  `json:"stop_id"`
 	StopSequence      int     `json:"stop_sequence"`
 	StopHeadsign      string  `json:"stop_headsign"`
 	PickupType        int     `json:"pickup_type"`
 	DropOffType       int     `json:"drop_off_type"`
+	ContinuousPickup  int     `json:"continuous_pickup"`
+	ContinuousDropOff int     `json:"continuous_drop_off"`
 	ShapeDistTraveled float64 `json:"shape_dist_traveled"`
 	Timepoint         int     `json:"timepoint"`
 }
@@ -56,4 +65,149 @@ type Stop struct {
 	ParentStation      string  `json:"parent_station"`
 	StopTimezone       string  `json:"stop_timezone"`
 	WheelchairBoarding int     `json:"wheelchair_boarding"`
+	LevelID            string  `json:"level_id"`
+	PlatformCode       string  `json:"platform_code"`
+}
+
+type Calendar struct {
+	ServiceID string `json:"service_id"`
+	Monday    int    `json:"monday"`
+	Tuesday   int    `json:"tuesday"`
+	Wednesday int    `json:"wednesday"`
+	Thursday  int    `json:"thursday"`
+	Friday    int    `json:"friday"`
+	Saturday  int    `json:"saturday"`
+	Sunday    int    `json:"sunday"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+type CalendarDate struct {
+	ServiceID     string `json:"service_id"`
+	Date          string `json:"date"`
+	ExceptionType int    `json:"exception_type"`
+}
+
+type Agency struct {
+	AgencyID       string `json:"agency_id"`
+	AgencyName     string `json:"agency_name"`
+	AgencyURL      string `json:"agency_url"`
+	AgencyTimezone string `json:"agency_timezone"`
+	AgencyLang     string `json:"agency_lang"`
+	AgencyPhone    string `json:"agency_phone"`
+	AgencyFareURL  string `json:"agency_fare_url"`
+	AgencyEmail    string `json:"agency_email"`
+}
+
+type FeedInfo struct {
+	FeedPublisherName string `json:"feed_publisher_name"`
+	FeedPublisherURL  string `json:"feed_publisher_url"`
+	FeedLang          string `json:"feed_lang"`
+	DefaultLang       string `json:"default_lang"`
+	FeedStartDate     string `json:"feed_start_date"`
+	FeedEndDate       string `json:"feed_end_date"`
+	FeedVersion       string `json:"feed_version"`
+	FeedContactEmail  string `json:"feed_contact_email"`
+	FeedContactURL    string `json:"feed_contact_url"`
+}
+
+// Transfer is a rule from transfers.txt for changing between two stops,
+// optionally limited to particular routes or trips. MinTransferTime is in
+// seconds. Generated marks a walking transfer derived from stop distance
+// rather than read from the feed.
+type Transfer struct {
+	FromStopID      string `json:"from_stop_id"`
+	ToStopID        string `json:"to_stop_id"`
+	FromRouteID     string `json:"from_route_id"`
+	ToRouteID       string `json:"to_route_id"`
+	FromTripID      string `json:"from_trip_id"`
+	ToTripID        string `json:"to_trip_id"`
+	TransferType    int    `json:"transfer_type"`
+	MinTransferTime int    `json:"min_transfer_time"`
+	Generated       bool   `json:"generated"`
+}
+
+// Frequency is a row of frequencies.txt: trip TripID runs every HeadwaySecs
+// seconds from StartTime until before EndTime. ExactTimes is 1 when the
+// runs keep exactly to that schedule, and 0 when only the headway is
+// promised.
+type Frequency struct {
+	TripID      string `json:"trip_id"`
+	StartTime   Time   `json:"start_time"`
+	EndTime     Time   `json:"end_time"`
+	HeadwaySecs int    `json:"headway_secs"`
+	ExactTimes  int    `json:"exact_times"`
+}
+
+// FareAttribute is a fare from fare_attributes.txt. Transfers is -1 when
+// the fare allows unlimited transfers, and TransferDuration is in seconds.
+type FareAttribute struct {
+	FareID           string  `json:"fare_id"`
+	Price            float64 `json:"price"`
+	CurrencyType     string  `json:"currency_type"`
+	PaymentMethod    int     `json:"payment_method"`
+	Transfers        int     `json:"transfers"`
+	AgencyID         string  `json:"agency_id"`
+	TransferDuration int     `json:"transfer_duration"`
+}
+
+// FareRule is a row of fare_rules.txt limiting a fare to a route, or to
+// journeys between or through zones.
+type FareRule struct {
+	FareID        string `json:"fare_id"`
+	RouteID       string `json:"route_id"`
+	OriginID      string `json:"origin_id"`
+	DestinationID string `json:"destination_id"`
+	ContainsID    string `json:"contains_id"`
+}
+
+// FareProduct is a fare product of GTFS Fares v2 from fare_products.txt.
+type FareProduct struct {
+	FareProductID   string  `json:"fare_product_id"`
+	FareProductName string  `json:"fare_product_name"`
+	FareMediaID     string  `json:"fare_media_id"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+}
+
+// FareLegRule is a row of fare_leg_rules.txt pricing a leg within a network
+// between two areas with a fare product.
+type FareLegRule struct {
+	LegGroupID    string `json:"leg_group_id"`
+	NetworkID     string `json:"network_id"`
+	FromAreaID    string `json:"from_area_id"`
+	ToAreaID      string `json:"to_area_id"`
+	FareProductID string `json:"fare_product_id"`
+	RulePriority  int    `json:"rule_priority"`
+}
+
+// StopArea is a row of stop_areas.txt placing a stop in a fare area.
+type StopArea struct {
+	AreaID string `json:"area_id"`
+	StopID string `json:"stop_id"`
+}
+
+// Pathway is a row of pathways.txt: a walkway, stairs, elevator or other
+// way between two locations of a station. Length is in meters and
+// TraversalTime in seconds.
+type Pathway struct {
+	PathwayID            string  `json:"pathway_id"`
+	FromStopID           string  `json:"from_stop_id"`
+	ToStopID             string  `json:"to_stop_id"`
+	PathwayMode          int     `json:"pathway_mode"`
+	IsBidirectional      int     `json:"is_bidirectional"`
+	Length               float64 `json:"length"`
+	TraversalTime        int     `json:"traversal_time"`
+	StairCount           int     `json:"stair_count"`
+	MaxSlope             float64 `json:"max_slope"`
+	MinWidth             float64 `json:"min_width"`
+	SignpostedAs         string  `json:"signposted_as"`
+	ReversedSignpostedAs string  `json:"reversed_signposted_as"`
+}
+
+// Level is a row of levels.txt, a floor of a station.
+type Level struct {
+	LevelID    string  `json:"level_id"`
+	LevelIndex float64 `json:"level_index"`
+	LevelName  string  `json:"level_name"`
 }
@@ -0,0 +1,214 @@
+package processing
+
+import (
+	"slices"
+	"sort"
+)
+
+// Sources of a Fare.
+const (
+	FareSourceAttributes = "fare_attributes"
+	FareSourceLegRules   = "fare_leg_rules"
+)
+
+// FareRide is a ride on one route from one stop to another, to be priced.
+// Zones holds the zone_id of each stop ridden through, boarding and
+// alighting included.
+type FareRide struct {
+	RouteID    string
+	AgencyID   string
+	NetworkID  string
+	FromStopID string
+	ToStopID   string
+	Zones      []string
+}
+
+// Fare is a price that applies to a ride, taken from fare_attributes.txt
+// or from a Fares v2 fare product.
+type Fare struct {
+	Source     string         `json:"source"`
+	FareID     string         `json:"fare_id"`
+	Price      float64        `json:"price"`
+	Currency   string         `json:"currency"`
+	Attribute  *FareAttribute `json:"fare_attribute,omitempty"`
+	Product    *FareProduct   `json:"fare_product,omitempty"`
+	LegGroupID string         `json:"leg_group_id,omitempty"`
+}
+
+// FareIndex prices rides with the fares of a feed, both the original
+// fare_attributes.txt and fare_rules.txt and, where the feed has them, the
+// fare products and leg rules of GTFS Fares v2.
+type FareIndex struct {
+	attributes []FareAttribute
+	rules      map[string][]FareRule
+	products   map[string][]FareProduct
+	legRules   []FareLegRule
+	stopAreas  map[string][]string
+	parents    map[string]string
+}
+
+// NewFareIndex indexes the fares of a feed. stops are used to let a
+// platform take the fare areas of its station.
+func NewFareIndex(attributes []FareAttribute, rules []FareRule, products []FareProduct, legRules []FareLegRule, stopAreas []StopArea, stops []Stop) *FareIndex {
+	index := &FareIndex{
+		attributes: attributes,
+		rules:      make(map[string][]FareRule),
+		products:   make(map[string][]FareProduct),
+		legRules:   legRules,
+		stopAreas:  make(map[string][]string),
+		parents:    make(map[string]string),
+	}
+	for _, rule := range rules {
+		index.rules[rule.FareID] = append(index.rules[rule.FareID], rule)
+	}
+	for _, product := range products {
+		index.products[product.FareProductID] = append(index.products[product.FareProductID], product)
+	}
+	for _, stopArea := range stopAreas {
+		index.stopAreas[stopArea.StopID] = append(index.stopAreas[stopArea.StopID], stopArea.AreaID)
+	}
+	for _, stop := range stops {
+		if stop.ParentStation != "" {
+			index.parents[stop.StopID] = stop.ParentStation
+		}
+	}
+	return index
+}
+
+// HasFares reports whether the feed describes any fares.
+func (ix *FareIndex) HasFares() bool {
+	return len(ix.attributes) > 0 || len(ix.legRules) > 0
+}
+
+// Ride returns the fares that apply to ride, cheapest first.
+func (ix *FareIndex) Ride(ride FareRide) []Fare {
+	var fares []Fare
+	for i := range ix.attributes {
+		attribute := &ix.attributes[i]
+		if attribute.AgencyID != "" && ride.AgencyID != "" && attribute.AgencyID != ride.AgencyID {
+			continue
+		}
+		if !fareRulesMatch(ix.rules[attribute.FareID], ride) {
+			continue
+		}
+		fares = append(fares, Fare{
+			Source:    FareSourceAttributes,
+			FareID:    attribute.FareID,
+			Price:     attribute.Price,
+			Currency:  attribute.CurrencyType,
+			Attribute: attribute,
+		})
+	}
+
+	for _, rule := range ix.matchLegRules(ride) {
+		for i := range ix.products[rule.FareProductID] {
+			product := &ix.products[rule.FareProductID][i]
+			fares = append(fares, Fare{
+				Source:     FareSourceLegRules,
+				FareID:     product.FareProductID,
+				Price:      product.Amount,
+				Currency:   product.Currency,
+				Product:    product,
+				LegGroupID: rule.LegGroupID,
+			})
+		}
+	}
+
+	sort.SliceStable(fares, func(i, j int) bool {
+		return fares[i].Price < fares[j].Price
+	})
+	return fares
+}
+
+// fareRulesMatch reports whether a fare with rules applies to ride. A fare
+// without rules applies to every ride. A rule applies when its route,
+// origin and destination are empty or match the ride; rules listing
+// contains_id apply together when the zones they list are exactly the
+// zones the ride passes through.
+func fareRulesMatch(rules []FareRule, ride FareRide) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	var origin, destination string
+	if len(ride.Zones) > 0 {
+		origin, destination = ride.Zones[0], ride.Zones[len(ride.Zones)-1]
+	}
+
+	contains := make(map[string]bool)
+	for _, rule := range rules {
+		if (rule.RouteID != "" && rule.RouteID != ride.RouteID) ||
+			(rule.OriginID != "" && rule.OriginID != origin) ||
+			(rule.DestinationID != "" && rule.DestinationID != destination) {
+			continue
+		}
+		if rule.ContainsID == "" {
+			return true
+		}
+		contains[rule.ContainsID] = true
+	}
+	if len(contains) == 0 {
+		return false
+	}
+	ridden := make(map[string]bool)
+	for _, zone := range ride.Zones {
+		if zone != "" {
+			ridden[zone] = true
+		}
+	}
+	if len(ridden) != len(contains) {
+		return false
+	}
+	for zone := range ridden {
+		if !contains[zone] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLegRules returns the Fares v2 leg rules for ride. Each of network,
+// from area and to area is matched in turn: rules naming the ride's value
+// are kept, or the rules leaving the field empty when none do. Of those,
+// only the rules with the highest rule_priority apply.
+func (ix *FareIndex) matchLegRules(ride FareRide) []FareLegRule {
+	rules := narrowLegRules(ix.legRules, func(rule FareLegRule) string { return rule.NetworkID }, []string{ride.NetworkID})
+	rules = narrowLegRules(rules, func(rule FareLegRule) string { return rule.FromAreaID }, ix.areas(ride.FromStopID))
+	rules = narrowLegRules(rules, func(rule FareLegRule) string { return rule.ToAreaID }, ix.areas(ride.ToStopID))
+
+	var matched []FareLegRule
+	for _, rule := range rules {
+		switch {
+		case len(matched) == 0 || rule.RulePriority > matched[0].RulePriority:
+			matched = []FareLegRule{rule}
+		case rule.RulePriority == matched[0].RulePriority:
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+func narrowLegRules(rules []FareLegRule, field func(FareLegRule) string, values []string) []FareLegRule {
+	var named, unnamed []FareLegRule
+	for _, rule := range rules {
+		value := field(rule)
+		switch {
+		case value == "":
+			unnamed = append(unnamed, rule)
+		case slices.Contains(values, value):
+			named = append(named, rule)
+		}
+	}
+	if len(named) > 0 {
+		return named
+	}
+	return unnamed
+}
+
+// areas returns the fare areas of a stop, including those of its station.
+func (ix *FareIndex) areas(stopID string) []string {
+	areas := ix.stopAreas[stopID]
+	if parent, found := ix.parents[stopID]; found {
+		areas = append(areas[:len(areas):len(areas)], ix.stopAreas[parent]...)
+	}
+	return areas
+}
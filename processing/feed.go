@@ -0,0 +1,302 @@
+package processing
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Feed is a GTFS static feed held in memory.
+type Feed struct {
+	Agencies       []Agency
+	Routes         []Route
+	Trips          []Trip
+	Shapes         []Shape
+	StopTimes      []StopTime
+	Stops          []Stop
+	Calendars      []Calendar
+	CalendarDates  []CalendarDate
+	Transfers      []Transfer
+	Frequencies    []Frequency
+	FareAttributes []FareAttribute
+	FareRules      []FareRule
+	FareProducts   []FareProduct
+	FareLegRules   []FareLegRule
+	StopAreas      []StopArea
+	Pathways       []Pathway
+	Levels         []Level
+	FeedInfo       *FeedInfo
+
+	// StopsWithoutLocation lists the stops whose stop_lat or stop_lon is
+	// empty, which their parsed 0 cannot tell apart from a real 0.
+	StopsWithoutLocation []string
+
+	Files []FileReport
+}
+
+// FileReport records whether a GTFS file was found while loading a feed.
+type FileReport struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Present  bool   `json:"present"`
+	Rows     int    `json:"rows"`
+}
+
+// MissingRequired lists the required GTFS files the feed did not contain.
+func (f *Feed) MissingRequired() []string {
+	var missing []string
+	for _, file := range f.Files {
+		if file.Required && !file.Present {
+			missing = append(missing, file.Name)
+		}
+	}
+	return missing
+}
+
+// LoadFeed parses the GTFS feed at path, which may be a directory of .txt
+// files or a .zip archive.
+func LoadFeed(path string) (*Feed, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feed: %w", err)
+	}
+	if !info.IsDir() {
+		return loadFeedZipFile(path)
+	}
+	return LoadFeedFrom(dirSource{dir: path})
+}
+
+// LoadFeedFrom parses every known GTFS file in src. Files missing from the
+// source are recorded in Feed.Files and leave the matching slice empty. If
+// any row fails to parse, the returned error is a *ParseErrors listing them.
+func LoadFeedFrom(src Source) (*Feed, error) {
+	feed := &Feed{}
+	files := []struct {
+		name     string
+		required bool
+		columns  []string
+		parse    func(row *Row)
+	}{
+		{"agency.txt", true, []string{"agency_name", "agency_url", "agency_timezone"},
+			func(row *Row) { feed.Agencies = append(feed.Agencies, agencyFromRow(row)) }},
+		{"routes.txt", true, []string{"route_id", "route_type"},
+			func(row *Row) { feed.Routes = append(feed.Routes, routeFromRow(row)) }},
+		{"trips.txt", true, []string{"route_id", "service_id", "trip_id"},
+			func(row *Row) { feed.Trips = append(feed.Trips, tripFromRow(row)) }},
+		{"stop_times.txt", true, []string{"trip_id", "stop_id", "stop_sequence"},
+			func(row *Row) { feed.StopTimes = append(feed.StopTimes, stopTimeFromRow(row)) }},
+		{"stops.txt", true, []string{"stop_id"},
+			func(row *Row) {
+				stop := stopFromRow(row)
+				if !row.Has("stop_lat") || !row.Has("stop_lon") {
+					feed.StopsWithoutLocation = append(feed.StopsWithoutLocation, stop.StopID)
+				}
+				feed.Stops = append(feed.Stops, stop)
+			}},
+		{"calendar.txt", false, []string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"},
+			func(row *Row) { feed.Calendars = append(feed.Calendars, calendarFromRow(row)) }},
+		{"calendar_dates.txt", false, []string{"service_id", "date", "exception_type"},
+			func(row *Row) { feed.CalendarDates = append(feed.CalendarDates, calendarDateFromRow(row)) }},
+		{"shapes.txt", false, []string{"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence"},
+			func(row *Row) { feed.Shapes = append(feed.Shapes, shapeFromRow(row)) }},
+		{"transfers.txt", false, []string{"transfer_type"},
+			func(row *Row) { feed.Transfers = append(feed.Transfers, transferFromRow(row)) }},
+		{"frequencies.txt", false, []string{"trip_id", "start_time", "end_time", "headway_secs"},
+			func(row *Row) { feed.Frequencies = append(feed.Frequencies, frequencyFromRow(row)) }},
+		{"fare_attributes.txt", false, []string{"fare_id", "price", "currency_type", "payment_method", "transfers"},
+			func(row *Row) { feed.FareAttributes = append(feed.FareAttributes, fareAttributeFromRow(row)) }},
+		{"fare_rules.txt", false, []string{"fare_id"},
+			func(row *Row) { feed.FareRules = append(feed.FareRules, fareRuleFromRow(row)) }},
+		{"fare_products.txt", false, []string{"fare_product_id", "amount", "currency"},
+			func(row *Row) { feed.FareProducts = append(feed.FareProducts, fareProductFromRow(row)) }},
+		{"fare_leg_rules.txt", false, []string{"fare_product_id"},
+			func(row *Row) { feed.FareLegRules = append(feed.FareLegRules, fareLegRuleFromRow(row)) }},
+		{"stop_areas.txt", false, []string{"area_id", "stop_id"},
+			func(row *Row) { feed.StopAreas = append(feed.StopAreas, stopAreaFromRow(row)) }},
+		{"pathways.txt", false, []string{"pathway_id", "from_stop_id", "to_stop_id", "pathway_mode", "is_bidirectional"},
+			func(row *Row) { feed.Pathways = append(feed.Pathways, pathwayFromRow(row)) }},
+		{"levels.txt", false, []string{"level_id", "level_index"},
+			func(row *Row) { feed.Levels = append(feed.Levels, levelFromRow(row)) }},
+		{"feed_info.txt", false, []string{"feed_publisher_name", "feed_publisher_url", "feed_lang"},
+			func(row *Row) {
+				feedInfo := feedInfoFromRow(row)
+				feed.FeedInfo = &feedInfo
+			}},
+	}
+
+	errs := &ParseErrors{}
+	for _, file := range files {
+		report := FileReport{Name: file.name, Required: file.required}
+		reader, err := src.Open(file.name)
+		if errors.Is(err, fs.ErrNotExist) {
+			feed.Files = append(feed.Files, report)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file.name, err)
+		}
+		report.Present = true
+		report.Rows, err = readRows(file.name, reader, file.columns, errs, file.parse)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.name, err)
+		}
+		feed.Files = append(feed.Files, report)
+	}
+	if errs.Total > 0 {
+		return nil, errs
+	}
+
+	fmt.Printf("Loaded feed: %d routes, %d trips, %d stops, %d stop times, %d shape points\n",
+		len(feed.Routes), len(feed.Trips), len(feed.Stops), len(feed.StopTimes), len(feed.Shapes))
+	return feed, nil
+}
+
+func agencyFromRow(row *Row) Agency {
+	return Agency{
+		AgencyID:       row.String("agency_id"),
+		AgencyName:     row.Required("agency_name"),
+		AgencyURL:      row.Required("agency_url"),
+		AgencyTimezone: row.Required("agency_timezone"),
+		AgencyLang:     row.String("agency_lang"),
+		AgencyPhone:    row.String("agency_phone"),
+		AgencyFareURL:  row.String("agency_fare_url"),
+		AgencyEmail:    row.String("agency_email"),
+	}
+}
+
+func calendarFromRow(row *Row) Calendar {
+	return Calendar{
+		ServiceID: row.Required("service_id"),
+		Monday:    row.RequiredInt("monday"),
+		Tuesday:   row.RequiredInt("tuesday"),
+		Wednesday: row.RequiredInt("wednesday"),
+		Thursday:  row.RequiredInt("thursday"),
+		Friday:    row.RequiredInt("friday"),
+		Saturday:  row.RequiredInt("saturday"),
+		Sunday:    row.RequiredInt("sunday"),
+		StartDate: row.Required("start_date"),
+		EndDate:   row.Required("end_date"),
+	}
+}
+
+func calendarDateFromRow(row *Row) CalendarDate {
+	return CalendarDate{
+		ServiceID:     row.Required("service_id"),
+		Date:          row.Required("date"),
+		ExceptionType: row.RequiredInt("exception_type"),
+	}
+}
+
+func transferFromRow(row *Row) Transfer {
+	return Transfer{
+		FromStopID:      row.String("from_stop_id"),
+		ToStopID:        row.String("to_stop_id"),
+		FromRouteID:     row.String("from_route_id"),
+		ToRouteID:       row.String("to_route_id"),
+		FromTripID:      row.String("from_trip_id"),
+		ToTripID:        row.String("to_trip_id"),
+		TransferType:    row.IntOr("transfer_type", TransferRecommended),
+		MinTransferTime: row.IntOr("min_transfer_time", 0),
+	}
+}
+
+func frequencyFromRow(row *Row) Frequency {
+	return Frequency{
+		TripID:      row.Required("trip_id"),
+		StartTime:   row.RequiredTime("start_time"),
+		EndTime:     row.RequiredTime("end_time"),
+		HeadwaySecs: row.RequiredInt("headway_secs"),
+		ExactTimes:  row.Int("exact_times"),
+	}
+}
+
+func fareAttributeFromRow(row *Row) FareAttribute {
+	return FareAttribute{
+		FareID:           row.Required("fare_id"),
+		Price:            row.RequiredFloat("price"),
+		CurrencyType:     row.Required("currency_type"),
+		PaymentMethod:    row.RequiredInt("payment_method"),
+		Transfers:        row.IntOr("transfers", -1),
+		AgencyID:         row.String("agency_id"),
+		TransferDuration: row.Int("transfer_duration"),
+	}
+}
+
+func fareRuleFromRow(row *Row) FareRule {
+	return FareRule{
+		FareID:        row.Required("fare_id"),
+		RouteID:       row.String("route_id"),
+		OriginID:      row.String("origin_id"),
+		DestinationID: row.String("destination_id"),
+		ContainsID:    row.String("contains_id"),
+	}
+}
+
+func fareProductFromRow(row *Row) FareProduct {
+	return FareProduct{
+		FareProductID:   row.Required("fare_product_id"),
+		FareProductName: row.String("fare_product_name"),
+		FareMediaID:     row.String("fare_media_id"),
+		Amount:          row.RequiredFloat("amount"),
+		Currency:        row.Required("currency"),
+	}
+}
+
+func fareLegRuleFromRow(row *Row) FareLegRule {
+	return FareLegRule{
+		LegGroupID:    row.String("leg_group_id"),
+		NetworkID:     row.String("network_id"),
+		FromAreaID:    row.String("from_area_id"),
+		ToAreaID:      row.String("to_area_id"),
+		FareProductID: row.Required("fare_product_id"),
+		RulePriority:  row.Int("rule_priority"),
+	}
+}
+
+func stopAreaFromRow(row *Row) StopArea {
+	return StopArea{
+		AreaID: row.Required("area_id"),
+		StopID: row.Required("stop_id"),
+	}
+}
+
+func pathwayFromRow(row *Row) Pathway {
+	return Pathway{
+		PathwayID:            row.Required("pathway_id"),
+		FromStopID:           row.Required("from_stop_id"),
+		ToStopID:             row.Required("to_stop_id"),
+		PathwayMode:          row.RequiredInt("pathway_mode"),
+		IsBidirectional:      row.RequiredInt("is_bidirectional"),
+		Length:               row.Float("length"),
+		TraversalTime:        row.Int("traversal_time"),
+		StairCount:           row.Int("stair_count"),
+		MaxSlope:             row.Float("max_slope"),
+		MinWidth:             row.Float("min_width"),
+		SignpostedAs:         row.String("signposted_as"),
+		ReversedSignpostedAs: row.String("reversed_signposted_as"),
+	}
+}
+
+func levelFromRow(row *Row) Level {
+	return Level{
+		LevelID:    row.Required("level_id"),
+		LevelIndex: row.RequiredFloat("level_index"),
+		LevelName:  row.String("level_name"),
+	}
+}
+
+func feedInfoFromRow(row *Row) FeedInfo {
+	return FeedInfo{
+		FeedPublisherName: row.Required("feed_publisher_name"),
+		FeedPublisherURL:  row.Required("feed_publisher_url"),
+		FeedLang:          row.Required("feed_lang"),
+		DefaultLang:       row.String("default_lang"),
+		FeedStartDate:     row.String("feed_start_date"),
+		FeedEndDate:       row.String("feed_end_date"),
+		FeedVersion:       row.String("feed_version"),
+		FeedContactEmail:  row.String("feed_contact_email"),
+		FeedContactURL:    row.String("feed_contact_url"),
+	}
+}
@@ -0,0 +1,99 @@
+package processing
+
+import "sort"
+
+// FrequencyRunID returns the trip ID given to the run of tripID that starts
+// at start. GTFS-RT tells the runs of a frequency-based trip apart by the
+// same trip_id and start_time.
+func FrequencyRunID(tripID string, start Time) string {
+	return tripID + "@" + start.String()
+}
+
+// FrequencyRun is a run expanded from frequencies.txt. TripID is the trip
+// it repeats. HeadwaySecs is the headway of a run whose times are estimates
+// (exact_times 0), and 0 for an exactly scheduled run.
+type FrequencyRun struct {
+	TripID      string
+	HeadwaySecs int
+}
+
+// ExpandFrequencies replaces each trip repeated by frequencies.txt with its
+// runs, so they can be indexed like any other trip. A run starts every
+// headway_secs from start_time until before end_time, and takes a copy of
+// the trip's stop times shifted so the first departure is at its start.
+// Frequency rows with no headway or an empty window are ignored, as are the
+// rows of trips without stop times. The runs are also returned by their
+// trip IDs. When the feed has no frequencies, trips and stopTimes are
+// returned as they are.
+func ExpandFrequencies(trips []Trip, stopTimes []StopTime, frequencies []Frequency) ([]Trip, []StopTime, map[string]FrequencyRun) {
+	windows := make(map[string][]Frequency)
+	for _, frequency := range frequencies {
+		if frequency.HeadwaySecs <= 0 || !frequency.StartTime.IsSet() || frequency.EndTime <= frequency.StartTime {
+			continue
+		}
+		windows[frequency.TripID] = append(windows[frequency.TripID], frequency)
+	}
+	if len(windows) == 0 {
+		return trips, stopTimes, nil
+	}
+
+	templates := make(map[string][]StopTime)
+	expandedStopTimes := make([]StopTime, 0, len(stopTimes))
+	for _, stopTime := range stopTimes {
+		if _, found := windows[stopTime.TripID]; found {
+			templates[stopTime.TripID] = append(templates[stopTime.TripID], stopTime)
+			continue
+		}
+		expandedStopTimes = append(expandedStopTimes, stopTime)
+	}
+
+	expandedTrips := make([]Trip, 0, len(trips))
+	runs := make(map[string]FrequencyRun)
+	for _, trip := range trips {
+		template := templates[trip.TripID]
+		if len(template) == 0 {
+			expandedTrips = append(expandedTrips, trip)
+			continue
+		}
+		sort.SliceStable(template, func(i, j int) bool {
+			return template[i].StopSequence < template[j].StopSequence
+		})
+		first := template[0].DepartureTime
+		if !first.IsSet() {
+			first = template[0].ArrivalTime
+		}
+		if !first.IsSet() {
+			// Without a first time the runs cannot be placed; the trip is
+			// kept as the feed describes it.
+			expandedTrips = append(expandedTrips, trip)
+			expandedStopTimes = append(expandedStopTimes, template...)
+			continue
+		}
+
+		for _, window := range windows[trip.TripID] {
+			for start := window.StartTime; start < window.EndTime; start += Time(window.HeadwaySecs) {
+				run := trip
+				run.TripID = FrequencyRunID(trip.TripID, start)
+				expandedTrips = append(expandedTrips, run)
+				frequencyRun := FrequencyRun{TripID: trip.TripID}
+				if window.ExactTimes == 0 {
+					frequencyRun.HeadwaySecs = window.HeadwaySecs
+				}
+				runs[run.TripID] = frequencyRun
+
+				offset := start - first
+				for _, stopTime := range template {
+					stopTime.TripID = run.TripID
+					if stopTime.ArrivalTime.IsSet() {
+						stopTime.ArrivalTime += offset
+					}
+					if stopTime.DepartureTime.IsSet() {
+						stopTime.DepartureTime += offset
+					}
+					expandedStopTimes = append(expandedStopTimes, stopTime)
+				}
+			}
+		}
+	}
+	return expandedTrips, expandedStopTimes, runs
+}
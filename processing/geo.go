@@ -0,0 +1,91 @@
+package processing
+
+import (
+	"math"
+	"sort"
+)
+
+// earthRadius is the mean radius of the Earth in meters.
+const earthRadius = 6371008.8
+
+// metersPerDegree is the length of one degree of latitude in meters.
+const metersPerDegree = earthRadius * math.Pi / 180
+
+// stopIndexCell is the size of a StopIndex grid cell in degrees, about 1.1km
+// north to south.
+const stopIndexCell = 0.01
+
+// Distance returns the great-circle distance in meters between two
+// coordinates, by the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(1, a)))
+}
+
+// StopIndex finds the stops near a point. Stops are bucketed in a grid of
+// stopIndexCell degree cells, so a query only measures the stops in the
+// cells its radius covers.
+type StopIndex struct {
+	cells map[[2]int][]Stop
+}
+
+// NearbyStop is a stop found by StopIndex.Nearby with its distance in
+// meters from the query point.
+type NearbyStop struct {
+	Stop
+	Distance float64 `json:"distance"`
+}
+
+// NewStopIndex indexes the stops and platforms (location_type 0) that have
+// coordinates. Stations, entrances and other nodes are left out as riders
+// board at their platforms.
+func NewStopIndex(stops []Stop) *StopIndex {
+	index := &StopIndex{cells: make(map[[2]int][]Stop)}
+	for _, stop := range stops {
+		if stop.LocationType != 0 || (stop.StopLat == 0 && stop.StopLon == 0) {
+			continue
+		}
+		cell := stopIndexCellOf(stop.StopLat, stop.StopLon)
+		index.cells[cell] = append(index.cells[cell], stop)
+	}
+	return index
+}
+
+func stopIndexCellOf(lat, lon float64) [2]int {
+	return [2]int{int(math.Floor(lat / stopIndexCell)), int(math.Floor(lon / stopIndexCell))}
+}
+
+// Nearby returns up to limit stops within radius meters of lat, lon, nearest
+// first. A limit of 0 or less returns every stop in range.
+func (ix *StopIndex) Nearby(lat, lon, radius float64, limit int) []NearbyStop {
+	dLat := radius / metersPerDegree
+	dLon := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > radius/earthRadius {
+		dLon = math.Min(180, dLat/cos)
+	}
+	low := stopIndexCellOf(lat-dLat, lon-dLon)
+	high := stopIndexCellOf(lat+dLat, lon+dLon)
+
+	nearby := []NearbyStop{}
+	for row := low[0]; row <= high[0]; row++ {
+		for col := low[1]; col <= high[1]; col++ {
+			for _, stop := range ix.cells[[2]int{row, col}] {
+				distance := Distance(lat, lon, stop.StopLat, stop.StopLon)
+				if distance <= radius {
+					nearby = append(nearby, NearbyStop{Stop: stop, Distance: distance})
+				}
+			}
+		}
+	}
+	sort.Slice(nearby, func(i, j int) bool {
+		return nearby[i].Distance < nearby[j].Distance
+	})
+	if limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	return nearby
+}
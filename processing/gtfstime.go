@@ -0,0 +1,96 @@
+package processing
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Time is a GTFS schedule time, counted in seconds from "noon minus 12h"
+// of the service day. It may exceed 24:00:00 for trips that run past
+// midnight.
+type Time int32
+
+// NoTime marks a stop time left empty in the feed, such as a stop between
+// timepoints.
+const NoTime Time = -1
+
+// ParseTime parses an H:MM:SS or HH:MM:SS GTFS time. An empty string
+// parses to NoTime.
+func ParseTime(s string) (Time, error) {
+	if s == "" {
+		return NoTime, nil
+	}
+	var hours, minutes, seconds int
+	var rest string
+	n, _ := fmt.Sscanf(s, "%d:%d:%d%s", &hours, &minutes, &seconds, &rest)
+	if n != 3 || hours < 0 || minutes < 0 || minutes > 59 || seconds < 0 || seconds > 59 {
+		return NoTime, fmt.Errorf("invalid time %q, expected HH:MM:SS", s)
+	}
+	return Time(hours*3600 + minutes*60 + seconds), nil
+}
+
+// NewTime returns the GTFS time for a number of hours, minutes and seconds.
+func NewTime(hours, minutes, seconds int) Time {
+	return Time(hours*3600 + minutes*60 + seconds)
+}
+
+// IsSet reports whether t holds a time rather than NoTime.
+func (t Time) IsSet() bool {
+	return t >= 0
+}
+
+// Seconds returns t as a count of seconds past the start of the service day.
+func (t Time) Seconds() int {
+	return int(t)
+}
+
+func (t Time) String() string {
+	if !t.IsSet() {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", t/3600, t/60%60, t%60)
+}
+
+// Add returns t shifted by d, rounded down to the second.
+func (t Time) Add(d time.Duration) Time {
+	return t + Time(d/time.Second)
+}
+
+// Sub returns the duration t-u.
+func (t Time) Sub(u Time) time.Duration {
+	return time.Duration(t-u) * time.Second
+}
+
+// On returns the instant t falls on for a service date in loc. GTFS
+// measures times from noon minus 12h, which is midnight except on days
+// when daylight saving time begins or ends, so the offset is applied to
+// that reference rather than to midnight.
+func (t Time) On(serviceDate time.Time, loc *time.Location) time.Time {
+	noon := time.Date(serviceDate.Year(), serviceDate.Month(), serviceDate.Day(), 12, 0, 0, 0, loc)
+	return noon.Add(-12 * time.Hour).Add(time.Duration(t) * time.Second)
+}
+
+// TimeOf returns the GTFS time of instant on serviceDate in loc, the
+// inverse of Time.On.
+func TimeOf(instant time.Time, serviceDate time.Time, loc *time.Location) Time {
+	start := Time(0).On(serviceDate, loc)
+	return Time(instant.Sub(start) / time.Second)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
@@ -0,0 +1,53 @@
+package processing
+
+import (
+	"fmt"
+	"time"
+)
+
+// Location returns the timezone of the feed's agencies, which GTFS requires
+// to be shared by every agency. All schedule times are relative to it.
+func (f *Feed) Location() (*time.Location, error) {
+	if len(f.Agencies) == 0 {
+		return nil, fmt.Errorf("feed has no agency timezone")
+	}
+	loc, err := time.LoadLocation(f.Agencies[0].AgencyTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agency timezone %q: %w", f.Agencies[0].AgencyTimezone, err)
+	}
+	return loc, nil
+}
+
+// Today returns midnight of the current calendar day in loc.
+func Today(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
+
+// ValidityWarnings describes problems with the feed's metadata as of date:
+// agencies in different timezones, or date falling outside the
+// feed_start_date..feed_end_date range from feed_info.txt.
+func (f *Feed) ValidityWarnings(date time.Time) []string {
+	var warnings []string
+	for i, agency := range f.Agencies {
+		if i > 0 && agency.AgencyTimezone != f.Agencies[0].AgencyTimezone {
+			warnings = append(warnings, fmt.Sprintf("agency %s uses timezone %s but %s uses %s",
+				agency.AgencyID, agency.AgencyTimezone, f.Agencies[0].AgencyID, f.Agencies[0].AgencyTimezone))
+		}
+	}
+	if _, err := f.Location(); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	if f.FeedInfo == nil {
+		return warnings
+	}
+
+	day := date.Format(DateLayout)
+	if f.FeedInfo.FeedStartDate != "" && day < f.FeedInfo.FeedStartDate {
+		warnings = append(warnings, fmt.Sprintf("feed is not valid until %s", f.FeedInfo.FeedStartDate))
+	}
+	if f.FeedInfo.FeedEndDate != "" && day > f.FeedInfo.FeedEndDate {
+		warnings = append(warnings, fmt.Sprintf("feed expired on %s", f.FeedInfo.FeedEndDate))
+	}
+	return warnings
+}
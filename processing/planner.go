@@ -0,0 +1,566 @@
+package processing
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// minChangeTime is the time allowed to change vehicles at a stop
+	// without a transfer rule of its own.
+	minChangeTime = 60
+	// noChange is the change time at a stop where transfers are not
+	// possible.
+	noChange = 24 * 60 * 60
+	// planHorizon is how far past the departure time connections are
+	// scanned.
+	planHorizon = 6 * time.Hour
+)
+
+// connection is a vehicle running between two consecutive stops of a trip.
+type connection struct {
+	trip      int32
+	from      int32
+	to        int32
+	position  int32 // of the departure in the trip's stop times
+	departure Time
+	arrival   Time
+	canBoard  bool
+	canAlight bool
+}
+
+type footpath struct {
+	to       int32
+	duration int32 // seconds
+	distance float64
+}
+
+// Timetable is the static schedule arranged for journey planning by the
+// Connection Scan Algorithm: every hop between two consecutive stops of a
+// trip, ordered by departure time, the transfers between stops and the
+// time needed to change vehicles at each stop.
+type Timetable struct {
+	stopIDs     []string
+	stops       map[string]int32
+	tripIDs     []string
+	tripService []string
+	tripRoute   []string
+	connections []connection
+	footpaths   [][]footpath
+	changeTimes []int32
+	// specific marks the stops with transfer rules for particular routes
+	// or trips, checked against transfers when changing there.
+	specific  map[int32]bool
+	transfers *TransferIndex
+}
+
+// NewTimetable builds the timetable from trips, their stop times in
+// stop_sequence order, the stops, and the transfers between them. Only
+// transfers that apply to any route and trip are used: a same stop rule
+// sets the change time at that stop, and a rule between two stops becomes
+// a footpath, taking min_transfer_time or the walk between them. Rules for
+// particular routes or trips are looked up when changing vehicles at the
+// same stop.
+func NewTimetable(trips []Trip, stopTimesByTrip map[string][]StopTime, stops map[string]Stop, transfers *TransferIndex) *Timetable {
+	timetable := &Timetable{
+		stops:     make(map[string]int32),
+		specific:  make(map[int32]bool),
+		transfers: transfers,
+	}
+	for _, trip := range trips {
+		stopTimes := stopTimesByTrip[trip.TripID]
+		if len(stopTimes) < 2 {
+			continue
+		}
+		tripIndex := int32(len(timetable.tripIDs))
+		timetable.tripIDs = append(timetable.tripIDs, trip.TripID)
+		timetable.tripService = append(timetable.tripService, trip.ServiceID)
+		timetable.tripRoute = append(timetable.tripRoute, trip.RouteID)
+		for i := 0; i+1 < len(stopTimes); i++ {
+			from, to := stopTimes[i], stopTimes[i+1]
+			if !from.DepartureTime.IsSet() || !to.ArrivalTime.IsSet() {
+				continue
+			}
+			timetable.connections = append(timetable.connections, connection{
+				trip:      tripIndex,
+				from:      timetable.stop(from.StopID),
+				to:        timetable.stop(to.StopID),
+				position:  int32(i),
+				departure: from.DepartureTime,
+				arrival:   to.ArrivalTime,
+				canBoard:  from.PickupType != 1,
+				canAlight: to.DropOffType != 1,
+			})
+		}
+	}
+	sort.SliceStable(timetable.connections, func(i, j int) bool {
+		return timetable.connections[i].departure < timetable.connections[j].departure
+	})
+
+	// Stops no trip serves can still start or end a journey by a transfer,
+	// such as a platform of a station that only has rules for the station.
+	for stopID := range stops {
+		timetable.stop(stopID)
+	}
+
+	timetable.footpaths = make([][]footpath, len(timetable.stopIDs))
+	timetable.changeTimes = make([]int32, len(timetable.stopIDs))
+	for from, stopID := range timetable.stopIDs {
+		timetable.changeTimes[from] = minChangeTime
+		seen := make(map[int32]bool)
+		for _, transfer := range transfers.Applying(stopID) {
+			if transfer.FromRouteID != "" || transfer.ToRouteID != "" || transfer.FromTripID != "" || transfer.ToTripID != "" {
+				timetable.specific[int32(from)] = true
+				continue
+			}
+			for _, toStopID := range transfers.Destinations(transfer) {
+				to, served := timetable.stops[toStopID]
+				if !served || seen[to] {
+					continue
+				}
+				seen[to] = true
+				if int(to) == from {
+					switch transfer.TransferType {
+					case TransferTimed:
+						timetable.changeTimes[from] = 0
+					case TransferMinTime:
+						timetable.changeTimes[from] = int32(transfer.MinTransferTime)
+					case TransferNotPossible:
+						timetable.changeTimes[from] = noChange
+					}
+					continue
+				}
+				if transfer.TransferType == TransferNotPossible {
+					continue
+				}
+				distance := Distance(stops[stopID].StopLat, stops[stopID].StopLon, stops[toStopID].StopLat, stops[toStopID].StopLon)
+				duration := WalkDuration(distance)
+				if transfer.TransferType == TransferMinTime {
+					duration = int32(transfer.MinTransferTime)
+				}
+				timetable.footpaths[from] = append(timetable.footpaths[from], footpath{
+					to:       to,
+					duration: duration,
+					distance: distance,
+				})
+			}
+		}
+	}
+	return timetable
+}
+
+func (t *Timetable) stop(stopID string) int32 {
+	index, found := t.stops[stopID]
+	if !found {
+		index = int32(len(t.stopIDs))
+		t.stops[stopID] = index
+		t.stopIDs = append(t.stopIDs, stopID)
+	}
+	return index
+}
+
+// Connections is the number of stop to stop hops in the timetable.
+func (t *Timetable) Connections() int {
+	return len(t.connections)
+}
+
+// WalkDuration is the time in seconds to walk distance meters.
+func WalkDuration(distance float64) int32 {
+	return int32(math.Ceil(distance / WalkSpeed))
+}
+
+// Access is a stop where a journey can start or end, and how long it takes
+// to walk between it and the journey's origin or destination.
+type Access struct {
+	StopID   string
+	Walk     int32 // seconds
+	Distance float64
+}
+
+// PlanRequest asks for journeys leaving after DepartAt from any of the
+// Origins to any of the Destinations.
+type PlanRequest struct {
+	Origins      []Access
+	Destinations []Access
+	DepartAt     time.Time
+	Itineraries  int
+	Calendar     *ServiceCalendar
+	Location     *time.Location
+	// Adjust, when set, replaces the scheduled time of the stop time at
+	// position in a trip's stop times running on serviceDate, e.g. with a
+	// realtime prediction. It returns false when the trip does not run.
+	Adjust func(tripID string, serviceDate time.Time, position int, scheduled time.Time) (time.Time, bool)
+}
+
+// Leg is one part of an itinerary: a walk, or a ride on one trip. Walks to
+// or from the journey's origin or destination have an empty stop ID at that
+// end. FromPosition and ToPosition index the trip's stop times.
+type Leg struct {
+	Mode         string    `json:"mode"`
+	FromStopID   string    `json:"from_stop_id,omitempty"`
+	ToStopID     string    `json:"to_stop_id,omitempty"`
+	Departure    time.Time `json:"departure"`
+	Arrival      time.Time `json:"arrival"`
+	Duration     int       `json:"duration"`
+	Distance     float64   `json:"distance,omitempty"`
+	TripID       string    `json:"trip_id,omitempty"`
+	ServiceDate  string    `json:"service_date,omitempty"`
+	FromPosition int       `json:"-"`
+	ToPosition   int       `json:"-"`
+}
+
+const (
+	LegWalk    = "walk"
+	LegTransit = "transit"
+)
+
+// Itinerary is one way of making a journey. Duration is in seconds.
+type Itinerary struct {
+	Departure time.Time `json:"departure"`
+	Arrival   time.Time `json:"arrival"`
+	Duration  int       `json:"duration"`
+	Transfers int       `json:"transfers"`
+	Legs      []Leg     `json:"legs"`
+}
+
+// scheduledConnection is a connection on one service date, at absolute
+// times in Unix seconds.
+type scheduledConnection struct {
+	*connection
+	serviceDate time.Time
+	leaves      int64
+	arrives     int64
+}
+
+// tripRun is one run of a trip, on the service date starting at
+// serviceDate in Unix seconds.
+type tripRun struct {
+	trip        int32
+	serviceDate int64
+}
+
+// Reached by, for a journey label.
+const (
+	reachedNone = iota
+	reachedAccess
+	reachedRide
+	reachedWalk
+)
+
+type journeyLabel struct {
+	arrival int64
+	ready   int64
+	reached int8
+	board   int32 // ride: the connection the trip was boarded on
+	alight  int32 // ride: the connection the trip was left after
+	from    int32 // walk: the stop walked from
+	walk    footpath
+	access  Access
+}
+
+// Plan finds up to req.Itineraries journeys, each the one arriving earliest
+// among those leaving after the previous one, by the Connection Scan
+// Algorithm over the trips running on the service days around DepartAt.
+func (t *Timetable) Plan(req PlanRequest) []Itinerary {
+	connections := t.scheduledConnections(req)
+	itineraries := []Itinerary{}
+	departAt := req.DepartAt.Unix()
+	for len(itineraries) < req.Itineraries {
+		itinerary, found := t.scan(req, connections, departAt)
+		if !found {
+			break
+		}
+		itineraries = append(itineraries, itinerary)
+		departAt = itinerary.Departure.Unix() + 1
+	}
+	return itineraries
+}
+
+// scheduledConnections returns the connections of the trips running on the
+// service dates that overlap the planning horizon, ordered by departure.
+func (t *Timetable) scheduledConnections(req PlanRequest) []scheduledConnection {
+	start := req.DepartAt.In(req.Location)
+	end := start.Add(planHorizon)
+	today := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, req.Location)
+
+	var scheduled []scheduledConnection
+	for offset := -1; offset <= 1; offset++ {
+		serviceDate := today.AddDate(0, 0, offset)
+		from := TimeOf(start, serviceDate, req.Location)
+		to := TimeOf(end, serviceDate, req.Location)
+		first := sort.Search(len(t.connections), func(i int) bool {
+			return t.connections[i].departure >= from
+		})
+		active := make(map[string]bool)
+		for i := first; i < len(t.connections) && t.connections[i].departure <= to; i++ {
+			c := &t.connections[i]
+			service := t.tripService[c.trip]
+			running, checked := active[service]
+			if !checked {
+				running = req.Calendar.IsActive(service, serviceDate)
+				active[service] = running
+			}
+			if !running {
+				continue
+			}
+			scheduled = append(scheduled, scheduledConnection{
+				connection:  c,
+				serviceDate: serviceDate,
+				leaves:      c.departure.On(serviceDate, req.Location).Unix(),
+				arrives:     c.arrival.On(serviceDate, req.Location).Unix(),
+			})
+		}
+	}
+
+	if req.Adjust != nil {
+		adjusted := scheduled[:0]
+		for _, c := range scheduled {
+			tripID := t.tripIDs[c.trip]
+			leaves, runs := req.Adjust(tripID, c.serviceDate, int(c.position), time.Unix(c.leaves, 0))
+			if !runs {
+				continue
+			}
+			arrives, runs := req.Adjust(tripID, c.serviceDate, int(c.position)+1, time.Unix(c.arrives, 0))
+			if !runs {
+				continue
+			}
+			c.leaves, c.arrives = leaves.Unix(), max(arrives.Unix(), leaves.Unix())
+			adjusted = append(adjusted, c)
+		}
+		scheduled = adjusted
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].leaves < scheduled[j].leaves
+	})
+	return scheduled
+}
+
+// scan runs one earliest arrival query from departAt.
+func (t *Timetable) scan(req PlanRequest, connections []scheduledConnection, departAt int64) (Itinerary, bool) {
+	labels := make([]journeyLabel, len(t.stopIDs))
+	for i := range labels {
+		labels[i].arrival = math.MaxInt64
+		labels[i].ready = math.MaxInt64
+	}
+	// The same trip runs once per service date, so boardings are kept by
+	// trip and date.
+	tripBoarded := make(map[tripRun]int32)
+
+	for _, access := range req.Origins {
+		stop, found := t.stops[access.StopID]
+		if !found {
+			continue
+		}
+		arrival := departAt + int64(access.Walk)
+		if arrival < labels[stop].arrival {
+			labels[stop] = journeyLabel{arrival: arrival, ready: arrival, reached: reachedAccess, access: access}
+		}
+	}
+	for _, access := range req.Origins {
+		if stop, found := t.stops[access.StopID]; found && labels[stop].reached == reachedAccess {
+			t.relaxFootpaths(labels, stop)
+		}
+	}
+
+	destinations := make(map[int32]Access)
+	for _, access := range req.Destinations {
+		if stop, found := t.stops[access.StopID]; found {
+			destinations[stop] = access
+		}
+	}
+	best := int64(math.MaxInt64)
+	for stop, access := range destinations {
+		if labels[stop].reached != reachedNone {
+			best = min(best, labels[stop].arrival+int64(access.Walk))
+		}
+	}
+
+	first := sort.Search(len(connections), func(i int) bool {
+		return connections[i].leaves >= departAt
+	})
+	for i := first; i < len(connections); i++ {
+		c := &connections[i]
+		if c.leaves >= best {
+			break
+		}
+		run := tripRun{trip: c.trip, serviceDate: c.serviceDate.Unix()}
+		board, onBoard := tripBoarded[run]
+		if !onBoard {
+			if !c.canBoard || t.ready(connections, labels[c.from], c) > c.leaves {
+				continue
+			}
+			board = int32(i)
+			tripBoarded[run] = board
+		}
+		if !c.canAlight || c.arrives >= labels[c.to].arrival {
+			continue
+		}
+		labels[c.to] = journeyLabel{
+			arrival: c.arrives,
+			ready:   c.arrives + int64(t.changeTimes[c.to]),
+			reached: reachedRide,
+			board:   board,
+			alight:  int32(i),
+		}
+		t.relaxFootpaths(labels, c.to)
+		for stop, access := range destinations {
+			if labels[stop].reached != reachedNone {
+				best = min(best, labels[stop].arrival+int64(access.Walk))
+			}
+		}
+	}
+
+	var target int32 = -1
+	for stop, access := range destinations {
+		if labels[stop].reached == reachedNone {
+			continue
+		}
+		arrival := labels[stop].arrival + int64(access.Walk)
+		if target < 0 || arrival < labels[target].arrival+int64(destinations[target].Walk) {
+			target = stop
+		}
+	}
+	if target < 0 {
+		return Itinerary{}, false
+	}
+	return t.itinerary(req, connections, labels, target, destinations[target])
+}
+
+// ready returns when a rider at the stop of label can board connection c.
+// Where the stop has transfer rules for particular routes or trips, the
+// rule for changing from the trip the rider arrived on to c's trip decides.
+func (t *Timetable) ready(connections []scheduledConnection, label journeyLabel, c *scheduledConnection) int64 {
+	if label.reached != reachedRide || !t.specific[c.from] {
+		return label.ready
+	}
+	arrived := connections[label.alight].trip
+	stopID := t.stopIDs[c.from]
+	transfer, found := t.transfers.Between(stopID, stopID, t.tripRoute[arrived], t.tripRoute[c.trip], t.tripIDs[arrived], t.tripIDs[c.trip])
+	if !found {
+		return label.ready
+	}
+	switch transfer.TransferType {
+	case TransferTimed, TransferInSeat:
+		return label.arrival
+	case TransferMinTime:
+		return label.arrival + int64(transfer.MinTransferTime)
+	case TransferNotPossible, TransferInSeatNotAllowed:
+		return math.MaxInt64
+	default:
+		return label.arrival + minChangeTime
+	}
+}
+
+func (t *Timetable) relaxFootpaths(labels []journeyLabel, from int32) {
+	for _, path := range t.footpaths[from] {
+		arrival := labels[from].arrival + int64(path.duration)
+		if arrival < labels[path.to].arrival {
+			labels[path.to] = journeyLabel{arrival: arrival, ready: arrival, reached: reachedWalk, from: from, walk: path}
+		}
+	}
+}
+
+// itinerary follows the labels back from the destination stop target to
+// the origin and lists the legs in travel order. Only itineraries with at
+// least one ride are returned; waiting time before the first ride is left
+// out by leaving the origin as late as possible.
+func (t *Timetable) itinerary(req PlanRequest, connections []scheduledConnection, labels []journeyLabel, target int32, egress Access) (Itinerary, bool) {
+	var legs []Leg
+	if egress.Walk > 0 {
+		arrival := labels[target].arrival
+		legs = append(legs, Leg{
+			Mode:       LegWalk,
+			FromStopID: t.stopIDs[target],
+			Departure:  time.Unix(arrival, 0),
+			Arrival:    time.Unix(arrival+int64(egress.Walk), 0),
+			Duration:   int(egress.Walk),
+			Distance:   egress.Distance,
+		})
+	}
+
+	stop := target
+	rides := 0
+	var access Access
+	reachedOrigin := false
+	for steps := 0; steps <= len(labels) && !reachedOrigin; steps++ {
+		label := labels[stop]
+		if label.reached == reachedAccess {
+			access = label.access
+			reachedOrigin = true
+			continue
+		}
+		switch label.reached {
+		case reachedRide:
+			board, alight := &connections[label.board], &connections[label.alight]
+			legs = append(legs, Leg{
+				Mode:         LegTransit,
+				FromStopID:   t.stopIDs[board.from],
+				ToStopID:     t.stopIDs[alight.to],
+				Departure:    time.Unix(board.leaves, 0),
+				Arrival:      time.Unix(alight.arrives, 0),
+				Duration:     int(alight.arrives - board.leaves),
+				TripID:       t.tripIDs[board.trip],
+				ServiceDate:  board.serviceDate.Format(DateLayout),
+				FromPosition: int(board.position),
+				ToPosition:   int(alight.position) + 1,
+			})
+			rides++
+			stop = board.from
+		case reachedWalk:
+			legs = append(legs, Leg{
+				Mode:       LegWalk,
+				FromStopID: t.stopIDs[label.from],
+				ToStopID:   t.stopIDs[stop],
+				Duration:   int(label.walk.duration),
+				Distance:   label.walk.distance,
+			})
+			stop = label.from
+		default:
+			return Itinerary{}, false
+		}
+	}
+	if !reachedOrigin || rides == 0 {
+		return Itinerary{}, false
+	}
+	if access.Walk > 0 {
+		legs = append(legs, Leg{
+			Mode:     LegWalk,
+			ToStopID: access.StopID,
+			Duration: int(access.Walk),
+			Distance: access.Distance,
+		})
+	}
+
+	// Legs were collected from the destination back; reverse them and
+	// place the walks, which have no fixed time, against the rides.
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+	for i := len(legs) - 1; i >= 0; i-- {
+		if legs[i].Mode != LegWalk || !legs[i].Departure.IsZero() {
+			continue
+		}
+		if i+1 < len(legs) {
+			legs[i].Arrival = legs[i+1].Departure
+			legs[i].Departure = legs[i].Arrival.Add(-time.Duration(legs[i].Duration) * time.Second)
+		}
+	}
+	for i := range legs {
+		if legs[i].Departure.IsZero() && i > 0 {
+			legs[i].Departure = legs[i-1].Arrival
+			legs[i].Arrival = legs[i].Departure.Add(time.Duration(legs[i].Duration) * time.Second)
+		}
+		legs[i].Departure = legs[i].Departure.In(req.Location)
+		legs[i].Arrival = legs[i].Arrival.In(req.Location)
+	}
+
+	departure, arrival := legs[0].Departure, legs[len(legs)-1].Arrival
+	return Itinerary{
+		Departure: departure,
+		Arrival:   arrival,
+		Duration:  int(arrival.Sub(departure) / time.Second),
+		Transfers: rides - 1,
+		Legs:      legs,
+	}, true
+}
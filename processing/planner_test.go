@@ -0,0 +1,182 @@
+package processing
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var plannerLocation = time.FixedZone("MST", -7*60*60)
+
+// testTimetable returns a weekday timetable of four stops. T1 runs from A
+// to B and T2 from C to D, with a three minute walk from B to C between
+// them; T3 runs from A to D past midnight. T4 runs a whole day, from E at
+// 00:30 to H at 24:20, so two of its runs can overlap.
+func testTimetable() (*Timetable, *ServiceCalendar) {
+	stops := []Stop{
+		{StopID: "A", StopLat: 39.70, StopLon: -104.99},
+		{StopID: "B", StopLat: 39.71, StopLon: -104.99},
+		{StopID: "C", StopLat: 39.7105, StopLon: -104.99},
+		{StopID: "D", StopLat: 39.75, StopLon: -104.99},
+		{StopID: "E", StopLat: 39.80, StopLon: -104.99},
+		{StopID: "F", StopLat: 39.85, StopLon: -104.99},
+		{StopID: "G", StopLat: 39.90, StopLon: -104.99},
+		{StopID: "H", StopLat: 39.95, StopLon: -104.99},
+	}
+	stopTime := func(tripID, stopID string, sequence int, at Time) StopTime {
+		return StopTime{TripID: tripID, StopID: stopID, StopSequence: sequence, ArrivalTime: at, DepartureTime: at}
+	}
+	trips := []Trip{
+		{RouteID: "R1", ServiceID: "WEEK", TripID: "T1"},
+		{RouteID: "R2", ServiceID: "WEEK", TripID: "T2"},
+		{RouteID: "R3", ServiceID: "WEEK", TripID: "T3"},
+		{RouteID: "R4", ServiceID: "WEEK", TripID: "T4"},
+	}
+	stopTimes := map[string][]StopTime{
+		"T1": {stopTime("T1", "A", 1, NewTime(8, 0, 0)), stopTime("T1", "B", 2, NewTime(8, 10, 0))},
+		"T2": {stopTime("T2", "C", 1, NewTime(8, 20, 0)), stopTime("T2", "D", 2, NewTime(8, 30, 0))},
+		"T3": {stopTime("T3", "A", 1, NewTime(23, 50, 0)), stopTime("T3", "D", 2, NewTime(24, 20, 0))},
+		"T4": {
+			stopTime("T4", "E", 1, NewTime(0, 30, 0)), stopTime("T4", "F", 2, NewTime(0, 40, 0)),
+			stopTime("T4", "G", 3, NewTime(24, 10, 0)), stopTime("T4", "H", 4, NewTime(24, 20, 0)),
+		},
+	}
+	transfers := []Transfer{
+		{FromStopID: "B", ToStopID: "C", TransferType: TransferMinTime, MinTransferTime: 180},
+	}
+
+	stopsByID := make(map[string]Stop)
+	for _, stop := range stops {
+		stopsByID[stop.StopID] = stop
+	}
+	index := NewTransferIndex(transfers, stops, NewStopIndex(stops))
+	calendar := NewServiceCalendar([]Calendar{{
+		ServiceID: "WEEK",
+		Monday:    1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1,
+		StartDate: "20250101", EndDate: "20251231",
+	}}, nil)
+	return NewTimetable(trips, stopTimes, stopsByID, index), calendar
+}
+
+// describeLegs lists legs as "mode from-to departure-arrival", with the
+// trip ID in place of the mode for a ride. Walks between rides end when the
+// next ride leaves.
+func describeLegs(legs []Leg) []string {
+	described := []string{}
+	for _, leg := range legs {
+		mode := leg.Mode
+		if leg.Mode == LegTransit {
+			mode = leg.TripID
+		}
+		described = append(described, fmt.Sprintf("%s %s-%s %s-%s", mode, leg.FromStopID, leg.ToStopID,
+			leg.Departure.Format("Jan 2 15:04"), leg.Arrival.Format("Jan 2 15:04")))
+	}
+	return described
+}
+
+func TestPlan(t *testing.T) {
+	timetable, calendar := testTimetable()
+	// Mar 3 2025 is a Monday and Mar 8 a Saturday.
+	monday := time.Date(2025, 3, 3, 7, 55, 0, 0, plannerLocation)
+
+	tests := []struct {
+		name     string
+		from, to string // A and D when empty
+		departAt time.Time
+		adjust   func(tripID string, serviceDate time.Time, position int, scheduled time.Time) (time.Time, bool)
+		want     []string
+	}{
+		{
+			name:     "transfer with a walk",
+			departAt: monday,
+			want: []string{
+				"T1 A-B Mar 3 08:00-Mar 3 08:10",
+				"walk B-C Mar 3 08:17-Mar 3 08:20",
+				"T2 C-D Mar 3 08:20-Mar 3 08:30",
+			},
+		},
+		{
+			name:     "past midnight",
+			departAt: time.Date(2025, 3, 3, 23, 45, 0, 0, plannerLocation),
+			want:     []string{"T3 A-D Mar 3 23:50-Mar 4 00:20"},
+		},
+		{
+			name:     "past midnight from the next day",
+			departAt: time.Date(2025, 3, 4, 0, 5, 0, 0, plannerLocation),
+			want:     nil,
+		},
+		{
+			// Boarding Monday's run at G does not put the rider on
+			// Tuesday's run from E.
+			name:     "another run of the same trip",
+			from:     "G",
+			to:       "F",
+			departAt: time.Date(2025, 3, 4, 0, 5, 0, 0, plannerLocation),
+			want:     nil,
+		},
+		{
+			name:     "inactive service day",
+			departAt: time.Date(2025, 3, 8, 7, 55, 0, 0, plannerLocation),
+			want:     nil,
+		},
+		{
+			name:     "cancelled connection",
+			departAt: monday,
+			adjust: func(tripID string, serviceDate time.Time, position int, scheduled time.Time) (time.Time, bool) {
+				return scheduled, tripID != "T2"
+			},
+			want: nil,
+		},
+		{
+			name:     "delayed connection",
+			departAt: monday,
+			adjust: func(tripID string, serviceDate time.Time, position int, scheduled time.Time) (time.Time, bool) {
+				if tripID == "T2" {
+					return scheduled.Add(5 * time.Minute), true
+				}
+				return scheduled, true
+			},
+			want: []string{
+				"T1 A-B Mar 3 08:00-Mar 3 08:10",
+				"walk B-C Mar 3 08:22-Mar 3 08:25",
+				"T2 C-D Mar 3 08:25-Mar 3 08:35",
+			},
+		},
+		{
+			name:     "missed transfer",
+			departAt: monday,
+			adjust: func(tripID string, serviceDate time.Time, position int, scheduled time.Time) (time.Time, bool) {
+				if tripID == "T1" {
+					return scheduled.Add(8 * time.Minute), true
+				}
+				return scheduled, true
+			},
+			want: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to := "A", "D"
+			if test.from != "" {
+				from, to = test.from, test.to
+			}
+			itineraries := timetable.Plan(PlanRequest{
+				Origins:      []Access{{StopID: from}},
+				Destinations: []Access{{StopID: to}},
+				DepartAt:     test.departAt,
+				Itineraries:  1,
+				Calendar:     calendar,
+				Location:     plannerLocation,
+				Adjust:       test.adjust,
+			})
+			var got []string
+			if len(itineraries) > 0 {
+				got = describeLegs(itineraries[0].Legs)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("legs = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
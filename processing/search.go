@@ -0,0 +1,260 @@
+package processing
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fields of a stop that are searched, with how much a match in each counts.
+const (
+	searchFieldName = iota
+	searchFieldCode
+	searchFieldDesc
+)
+
+var searchFieldWeights = [...]float64{
+	searchFieldName: 1,
+	searchFieldCode: 1,
+	searchFieldDesc: 0.5,
+}
+
+// How much a query token scores for each kind of match against a term.
+const (
+	searchExact  = 1.0
+	searchPrefix = 0.7
+	searchTypo   = 0.5
+)
+
+// searchAbbreviations maps the abbreviations agencies use on signs to the
+// spelled out word. Stops are indexed under both, so "Mississippi Avenue"
+// and a partly typed "Mississippi Aven" both find "Mississippi Ave".
+var searchAbbreviations = map[string]string{
+	"ave":  "avenue",
+	"st":   "street",
+	"blvd": "boulevard",
+	"pkwy": "parkway",
+	"rd":   "road",
+	"dr":   "drive",
+	"pl":   "place",
+	"ct":   "court",
+	"hwy":  "highway",
+	"stn":  "station",
+	"n":    "north",
+	"s":    "south",
+	"e":    "east",
+	"w":    "west",
+}
+
+// searchStopWords are left out of queries, as stop names write them as
+// symbols ("&") that are not indexed.
+var searchStopWords = map[string]bool{
+	"and": true,
+	"at":  true,
+}
+
+// StopSearchIndex finds stops by name, code or description. Text is split
+// into lower case words and each query word matches a stop word exactly, as
+// a prefix, or with one or two typos.
+type StopSearchIndex struct {
+	stops    []Stop
+	terms    []string
+	postings map[string][]searchPosting
+}
+
+type searchPosting struct {
+	stop  int
+	field int
+}
+
+// StopMatch is a stop found by StopSearchIndex.Search. A higher score is a
+// better match.
+type StopMatch struct {
+	Stop
+	Score float64 `json:"score"`
+}
+
+// SearchTokens splits text into lower case words, dropping stop words.
+func SearchTokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if !searchStopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+func (ix *StopSearchIndex) add(term string, posting searchPosting) {
+	postings := ix.postings[term]
+	if len(postings) > 0 && postings[len(postings)-1] == posting {
+		return
+	}
+	ix.postings[term] = append(postings, posting)
+}
+
+// NewStopSearchIndex indexes the stops and stations (location_type 0 and 1),
+// the places riders look up by name.
+func NewStopSearchIndex(stops []Stop) *StopSearchIndex {
+	index := &StopSearchIndex{postings: make(map[string][]searchPosting)}
+	for _, stop := range stops {
+		if stop.LocationType > 1 {
+			continue
+		}
+		position := len(index.stops)
+		index.stops = append(index.stops, stop)
+		fields := [...]string{
+			searchFieldName: stop.StopName,
+			searchFieldCode: stop.StopCode,
+			searchFieldDesc: stop.StopDesc,
+		}
+		for field, text := range fields {
+			for _, token := range SearchTokens(text) {
+				index.add(token, searchPosting{position, field})
+				if expansion, found := searchAbbreviations[token]; found {
+					index.add(expansion, searchPosting{position, field})
+				}
+			}
+		}
+	}
+	index.terms = make([]string, 0, len(index.postings))
+	for term := range index.postings {
+		index.terms = append(index.terms, term)
+	}
+	sort.Strings(index.terms)
+	return index
+}
+
+// Search returns up to limit stops matching every word of query, best
+// first. A stop whose code equals the query is ranked above the rest.
+func (ix *StopSearchIndex) Search(query string, limit int) []StopMatch {
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 {
+		return []StopMatch{}
+	}
+
+	var scores map[int]float64
+	for _, token := range tokens {
+		tokenScores := make(map[int]float64)
+		for term, match := range ix.matchTerms(token) {
+			for _, posting := range ix.postings[term] {
+				score := match * searchFieldWeights[posting.field]
+				if score > tokenScores[posting.stop] {
+					tokenScores[posting.stop] = score
+				}
+			}
+		}
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for stop, score := range scores {
+			if tokenScore, found := tokenScores[stop]; found {
+				scores[stop] = score + tokenScore
+			} else {
+				delete(scores, stop)
+			}
+		}
+	}
+
+	matches := make([]StopMatch, 0, len(scores))
+	for position, score := range scores {
+		stop := ix.stops[position]
+		if stop.StopCode != "" && strings.EqualFold(stop.StopCode, strings.TrimSpace(query)) {
+			score += float64(len(tokens))
+		}
+		matches = append(matches, StopMatch{Stop: stop, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if len(matches[i].StopName) != len(matches[j].StopName) {
+			return len(matches[i].StopName) < len(matches[j].StopName)
+		}
+		return matches[i].StopID < matches[j].StopID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// matchTerms returns the indexed terms token matches, each with the score of
+// its best kind of match.
+func (ix *StopSearchIndex) matchTerms(token string) map[string]float64 {
+	matches := make(map[string]float64)
+	for i := sort.SearchStrings(ix.terms, token); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], token); i++ {
+		if ix.terms[i] == token {
+			matches[token] = searchExact
+		} else {
+			matches[ix.terms[i]] = searchPrefix
+		}
+	}
+
+	maxEdits := searchMaxEdits(token)
+	if maxEdits == 0 {
+		return matches
+	}
+	tokenRunes := []rune(token)
+	for _, term := range ix.terms {
+		if _, found := matches[term]; found {
+			continue
+		}
+		termRunes := []rune(term)
+		if diff := len(termRunes) - len(tokenRunes); diff > maxEdits || -diff > maxEdits {
+			continue
+		}
+		if editDistance(tokenRunes, termRunes, maxEdits) <= maxEdits {
+			matches[term] = searchTypo
+		}
+	}
+	return matches
+}
+
+// searchMaxEdits is how many typos a query word may have: none for short
+// words, where one typo makes a different word, or for numbers such as stop
+// codes, and up to two for long words.
+func searchMaxEdits(token string) int {
+	if strings.ContainsFunc(token, unicode.IsDigit) {
+		return 0
+	}
+	switch length := len([]rune(token)); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the Levenshtein distance between a and b, or a value
+// above limit as soon as the distance is known to exceed it.
+func editDistance(a, b []rune, limit int) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
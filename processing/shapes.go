@@ -0,0 +1,86 @@
+package processing
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// GroupShapes collects shape points by shape ID, each ordered by
+// shape_pt_sequence.
+func GroupShapes(shapes []Shape) map[string][]Shape {
+	grouped := make(map[string][]Shape)
+	for _, shape := range shapes {
+		grouped[shape.ShapeID] = append(grouped[shape.ShapeID], shape)
+	}
+	for _, points := range grouped {
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].ShapePtSequence < points[j].ShapePtSequence
+		})
+	}
+	return grouped
+}
+
+// EncodePolyline encodes ordered shape points in the Google encoded
+// polyline format with 5 digits of precision.
+func EncodePolyline(points []Shape) string {
+	var b strings.Builder
+	var prevLat, prevLon int64
+	for _, point := range points {
+		lat := int64(math.Round(point.ShapePtLat * 1e5))
+		lon := int64(math.Round(point.ShapePtLon * 1e5))
+		encodePolylineValue(&b, lat-prevLat)
+		encodePolylineValue(&b, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return b.String()
+}
+
+func encodePolylineValue(b *strings.Builder, value int64) {
+	value <<= 1
+	if value < 0 {
+		value = ^value
+	}
+	for value >= 0x20 {
+		b.WriteByte(byte((0x20 | (value & 0x1f)) + 63))
+		value >>= 5
+	}
+	b.WriteByte(byte(value + 63))
+}
+
+// GeoJSONGeometry is a GeoJSON geometry with [longitude, latitude] positions.
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// ShapeLineString returns ordered shape points as a GeoJSON LineString
+// feature, carrying the shape ID and total distance as properties.
+func ShapeLineString(shapeID string, points []Shape) GeoJSONFeature {
+	coordinates := make([][2]float64, len(points))
+	for i, point := range points {
+		coordinates[i] = [2]float64{point.ShapePtLon, point.ShapePtLat}
+	}
+	properties := map[string]interface{}{
+		"shape_id": shapeID,
+	}
+	if len(points) > 0 {
+		properties["shape_dist_traveled"] = points[len(points)-1].ShapeDistTraveled
+	}
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   GeoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,220 @@
+package processing
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// snapshotFormat is bumped whenever the layout of Feed or its types changes,
+// so snapshots written by an older build are ignored rather than misread.
+const snapshotFormat = 7
+
+// ErrSnapshotStale is returned by ReadSnapshot when the snapshot was written
+// for different inputs or by an incompatible build.
+var ErrSnapshotStale = errors.New("feed snapshot is stale")
+
+// SnapshotKey identifies the inputs a snapshot was built from.
+type SnapshotKey struct {
+	Format      int
+	FeedVersion string
+	InputHash   string
+}
+
+// FeedSnapshotKey computes the key of the GTFS feed at path: the
+// feed_version from feed_info.txt and a SHA-256 of the input files. For a
+// directory every .txt file is hashed by name and content; for a zip the
+// archive bytes are hashed.
+func FeedSnapshotKey(path string) (SnapshotKey, error) {
+	key := SnapshotKey{Format: snapshotFormat}
+	info, err := os.Stat(path)
+	if err != nil {
+		return key, fmt.Errorf("failed to open feed: %w", err)
+	}
+
+	hash := sha256.New()
+	var files []string
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.txt"))
+		if err != nil {
+			return key, err
+		}
+		sort.Strings(files)
+	} else {
+		files = []string{path}
+	}
+	for _, file := range files {
+		fmt.Fprintf(hash, "%s\n", filepath.Base(file))
+		if err := hashFile(hash, file); err != nil {
+			return key, err
+		}
+	}
+	key.InputHash = hex.EncodeToString(hash.Sum(nil))
+
+	if info.IsDir() {
+		key.FeedVersion, err = peekFeedVersion(dirSource{dir: path})
+	} else {
+		key.FeedVersion, err = peekZipFeedVersion(path)
+	}
+	return key, err
+}
+
+// FeedFingerprint summarises the names, sizes and modification times of the
+// GTFS feed files at path. It is cheap enough to poll and changes whenever a
+// file is added, removed or replaced, unlike FeedSnapshotKey which reads
+// every byte of the feed.
+func FeedFingerprint(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open feed: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.txt"))
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(files)
+	}
+
+	hash := sha256.New()
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s %d %d\n", filepath.Base(file), info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// peekFeedVersion reads only feed_info.txt for its feed_version.
+func peekFeedVersion(src Source) (string, error) {
+	reader, err := src.Open("feed_info.txt")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	version := ""
+	errs := &ParseErrors{}
+	_, err = readRows("feed_info.txt", reader, nil, errs, func(row *Row) {
+		version = row.String("feed_version")
+	})
+	return version, err
+}
+
+// WriteSnapshot stores feed in a gob encoded file at path under key. The
+// file is written beside path and renamed into place, so a reader never
+// sees a partial snapshot.
+func WriteSnapshot(path string, key SnapshotKey, feed *Feed) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := gob.NewEncoder(tmp)
+	if err := encoder.Encode(key); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := encoder.Encode(feed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadSnapshot loads the feed stored at path if it was written under key,
+// and returns ErrSnapshotStale otherwise.
+func ReadSnapshot(path string, key SnapshotKey) (*Feed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(file)
+	var stored SnapshotKey
+	if err := decoder.Decode(&stored); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotStale, err)
+	}
+	if stored != key {
+		return nil, ErrSnapshotStale
+	}
+	feed := &Feed{}
+	if err := decoder.Decode(feed); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return feed, nil
+}
+
+// LoadFeedCached loads the GTFS feed at path from the snapshot at
+// snapshotPath when it matches the current inputs, and otherwise parses
+// the feed and writes a fresh snapshot for the next start.
+func LoadFeedCached(path string, snapshotPath string) (*Feed, error) {
+	key, err := FeedSnapshotKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := ReadSnapshot(snapshotPath, key)
+	if err == nil {
+		fmt.Printf("Loaded feed %s from snapshot %s\n", snapshotLabel(key), snapshotPath)
+		return feed, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("Ignoring feed snapshot:", err)
+	}
+
+	feed, err = LoadFeed(path)
+	if err != nil {
+		return nil, err
+	}
+	// The key is taken again after loading: if the files changed while
+	// they were read, the feed may not match either key and is not cached.
+	if loadedKey, err := FeedSnapshotKey(path); err != nil || loadedKey != key {
+		fmt.Println("Not writing feed snapshot: the feed changed while it was loaded")
+		return feed, nil
+	}
+	if err := WriteSnapshot(snapshotPath, key, feed); err != nil {
+		fmt.Println("Error writing feed snapshot:", err)
+	} else {
+		fmt.Printf("Wrote feed %s snapshot to %s\n", snapshotLabel(key), snapshotPath)
+	}
+	return feed, nil
+}
+
+func snapshotLabel(key SnapshotKey) string {
+	version := key.FeedVersion
+	if version == "" {
+		version = "(unversioned)"
+	}
+	return fmt.Sprintf("%s@%s", version, key.InputHash[:12])
+}
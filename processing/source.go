@@ -0,0 +1,82 @@
+package processing
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Source opens the files of a GTFS feed by name, e.g. "stops.txt".
+// Open returns an error wrapping fs.ErrNotExist when the file is absent.
+type Source interface {
+	Open(name string) (io.ReadCloser, error)
+}
+
+type dirSource struct {
+	dir string
+}
+
+func (s dirSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+type zipSource struct {
+	files map[string]*zip.File
+}
+
+// newZipSource indexes the archive members by base name, so feeds zipped
+// with an enclosing folder (google_transit/stops.txt) are read the same way.
+func newZipSource(reader *zip.Reader) zipSource {
+	files := make(map[string]*zip.File)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Base(file.Name)
+		if _, exists := files[name]; !exists {
+			files[name] = file
+		}
+	}
+	return zipSource{files: files}
+}
+
+func (s zipSource) Open(name string) (io.ReadCloser, error) {
+	file, found := s.files[name]
+	if !found {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return file.Open()
+}
+
+// LoadFeedZip parses a GTFS feed from the bytes of a zip archive, such as an
+// uploaded google_transit.zip.
+func LoadFeedZip(data []byte) (*Feed, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feed archive: %w", err)
+	}
+	return LoadFeedFrom(newZipSource(reader))
+}
+
+func loadFeedZipFile(zipPath string) (*Feed, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feed archive: %w", err)
+	}
+	defer reader.Close()
+	return LoadFeedFrom(newZipSource(&reader.Reader))
+}
+
+func peekZipFeedVersion(zipPath string) (string, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open feed archive: %w", err)
+	}
+	defer reader.Close()
+	return peekFeedVersion(newZipSource(&reader.Reader))
+}
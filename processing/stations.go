@@ -0,0 +1,191 @@
+package processing
+
+import (
+	"slices"
+	"sort"
+)
+
+// Values of location_type in stops.txt.
+const (
+	LocationStop         = 0
+	LocationStation      = 1
+	LocationEntrance     = 2
+	LocationGenericNode  = 3
+	LocationBoardingArea = 4
+)
+
+// Values of pathway_mode in pathways.txt.
+const (
+	PathwayWalkway        = 1
+	PathwayStairs         = 2
+	PathwayMovingSidewalk = 3
+	PathwayEscalator      = 4
+	PathwayElevator       = 5
+	PathwayFareGate       = 6
+	PathwayExitGate       = 7
+)
+
+var pathwayModeNames = map[int]string{
+	PathwayWalkway:        "walkway",
+	PathwayStairs:         "stairs",
+	PathwayMovingSidewalk: "moving_sidewalk",
+	PathwayEscalator:      "escalator",
+	PathwayElevator:       "elevator",
+	PathwayFareGate:       "fare_gate",
+	PathwayExitGate:       "exit_gate",
+}
+
+// PathwayModeName returns the name of a pathway_mode, or "unknown".
+func PathwayModeName(mode int) string {
+	if name, found := pathwayModeNames[mode]; found {
+		return name
+	}
+	return "unknown"
+}
+
+// StepFree reports whether a pathway can be used without climbing steps:
+// it is neither stairs nor an escalator and has no stair_count.
+func (p Pathway) StepFree() bool {
+	return p.PathwayMode != PathwayStairs && p.PathwayMode != PathwayEscalator && p.StairCount == 0
+}
+
+// Station is a station of the feed with the locations inside it, by
+// location_type, and the pathways between them. Boarding areas belong to a
+// platform rather than directly to the station.
+type Station struct {
+	StationID     string
+	Platforms     []string
+	Entrances     []string
+	Nodes         []string
+	BoardingAreas map[string][]string
+	Pathways      []Pathway
+	Levels        []Level
+}
+
+// StationIndex models the station hierarchy of a feed: stations, the
+// platforms, entrances and nodes whose parent_station they are, and the
+// boarding areas of those platforms.
+type StationIndex struct {
+	stations  map[string]*Station
+	stationOf map[string]string
+}
+
+// NewStationIndex builds the stations of a feed from its stops, pathways
+// and levels.
+func NewStationIndex(stops []Stop, pathways []Pathway, levels []Level) *StationIndex {
+	index := &StationIndex{
+		stations:  make(map[string]*Station),
+		stationOf: make(map[string]string),
+	}
+	for _, stop := range stops {
+		if stop.LocationType == LocationStation {
+			index.stations[stop.StopID] = &Station{StationID: stop.StopID, BoardingAreas: make(map[string][]string)}
+		}
+	}
+
+	// Boarding areas hang off platforms, so stations are resolved in two
+	// passes.
+	for _, stop := range stops {
+		station, found := index.stations[stop.ParentStation]
+		if !found {
+			continue
+		}
+		index.stationOf[stop.StopID] = station.StationID
+		switch stop.LocationType {
+		case LocationStop:
+			station.Platforms = append(station.Platforms, stop.StopID)
+		case LocationEntrance:
+			station.Entrances = append(station.Entrances, stop.StopID)
+		case LocationGenericNode:
+			station.Nodes = append(station.Nodes, stop.StopID)
+		}
+	}
+	for _, stop := range stops {
+		if stop.LocationType != LocationBoardingArea {
+			continue
+		}
+		stationID, found := index.stationOf[stop.ParentStation]
+		if !found {
+			continue
+		}
+		index.stationOf[stop.StopID] = stationID
+		station := index.stations[stationID]
+		station.BoardingAreas[stop.ParentStation] = append(station.BoardingAreas[stop.ParentStation], stop.StopID)
+	}
+
+	for _, pathway := range pathways {
+		if stationID, found := index.stationOf[pathway.FromStopID]; found {
+			index.stations[stationID].Pathways = append(index.stations[stationID].Pathways, pathway)
+		}
+	}
+
+	levelsByID := make(map[string]Level, len(levels))
+	for _, level := range levels {
+		levelsByID[level.LevelID] = level
+	}
+	for _, stop := range stops {
+		station, found := index.Station(stop.StopID)
+		level, hasLevel := levelsByID[stop.LevelID]
+		if !found || !hasLevel || slices.ContainsFunc(station.Levels, func(known Level) bool { return known.LevelID == level.LevelID }) {
+			continue
+		}
+		station.Levels = append(station.Levels, level)
+	}
+	for _, station := range index.stations {
+		sort.Slice(station.Levels, func(i, j int) bool {
+			return station.Levels[i].LevelIndex < station.Levels[j].LevelIndex
+		})
+	}
+	return index
+}
+
+// Station returns the station stopID is, or the station it lies within.
+func (ix *StationIndex) Station(stopID string) (*Station, bool) {
+	if stationID, found := ix.stationOf[stopID]; found {
+		stopID = stationID
+	}
+	station, found := ix.stations[stopID]
+	return station, found
+}
+
+// Stations returns how many stations the feed has.
+func (ix *StationIndex) Stations() int {
+	return len(ix.stations)
+}
+
+// StepFreeFrom returns the locations of the station that can be reached
+// from one of origins along step-free pathways, following one-way pathways
+// only in their direction. It is nil when the station has no pathways, as
+// nothing is then known about its accessibility.
+func (s *Station) StepFreeFrom(origins ...string) map[string]bool {
+	if len(s.Pathways) == 0 {
+		return nil
+	}
+	next := make(map[string][]string)
+	for _, pathway := range s.Pathways {
+		if !pathway.StepFree() {
+			continue
+		}
+		next[pathway.FromStopID] = append(next[pathway.FromStopID], pathway.ToStopID)
+		if pathway.IsBidirectional == 1 {
+			next[pathway.ToStopID] = append(next[pathway.ToStopID], pathway.FromStopID)
+		}
+	}
+
+	reached := make(map[string]bool)
+	queue := append([]string(nil), origins...)
+	for _, origin := range origins {
+		reached[origin] = true
+	}
+	for len(queue) > 0 {
+		stopID := queue[0]
+		queue = queue[1:]
+		for _, to := range next[stopID] {
+			if !reached[to] {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return reached
+}
@@ -0,0 +1,85 @@
+package processing
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// IndexStopTimes copies stopTimes, sorts the copy by trip and
+// stop_sequence, fills in times left empty between timepoints, and indexes
+// it two ways: byTrip holds each trip's stop times as a sub-slice of the
+// copy, and byStop points into it for every stop, ordered by departure
+// time. stopTimes itself is left as published. The indexes share the one
+// copy, as the stop times of a large feed number in the millions.
+func IndexStopTimes(stopTimes []StopTime) (byTrip map[string][]StopTime, byStop map[string][]*StopTime) {
+	stopTimes = slices.Clone(stopTimes)
+	sort.SliceStable(stopTimes, func(i, j int) bool {
+		if stopTimes[i].TripID != stopTimes[j].TripID {
+			return stopTimes[i].TripID < stopTimes[j].TripID
+		}
+		return stopTimes[i].StopSequence < stopTimes[j].StopSequence
+	})
+
+	byTrip = make(map[string][]StopTime)
+	start := 0
+	for i := 1; i <= len(stopTimes); i++ {
+		if i == len(stopTimes) || stopTimes[i].TripID != stopTimes[start].TripID {
+			tripStopTimes := stopTimes[start:i:i]
+			interpolateTimes(tripStopTimes)
+			byTrip[stopTimes[start].TripID] = tripStopTimes
+			start = i
+		}
+	}
+
+	byStop = make(map[string][]*StopTime)
+	for i := range stopTimes {
+		byStop[stopTimes[i].StopID] = append(byStop[stopTimes[i].StopID], &stopTimes[i])
+	}
+	for _, stopStopTimes := range byStop {
+		sort.SliceStable(stopStopTimes, func(i, j int) bool {
+			return stopStopTimes[i].DepartureTime < stopStopTimes[j].DepartureTime
+		})
+	}
+	return byTrip, byStop
+}
+
+// interpolateTimes fills in the arrival and departure times a trip leaves
+// empty between timepoints. Times are spread by shape_dist_traveled when
+// the feed provides it, and evenly between the surrounding timepoints
+// otherwise.
+func interpolateTimes(tripStopTimes []StopTime) {
+	for i := range tripStopTimes {
+		stopTime := &tripStopTimes[i]
+		if !stopTime.ArrivalTime.IsSet() {
+			stopTime.ArrivalTime = stopTime.DepartureTime
+		}
+		if !stopTime.DepartureTime.IsSet() {
+			stopTime.DepartureTime = stopTime.ArrivalTime
+		}
+	}
+
+	prev := -1
+	for i, stopTime := range tripStopTimes {
+		if !stopTime.DepartureTime.IsSet() {
+			continue
+		}
+		if prev >= 0 && i-prev > 1 {
+			from, to := tripStopTimes[prev], tripStopTimes[i]
+			span := to.ArrivalTime - from.DepartureTime
+			useDistance := to.ShapeDistTraveled > from.ShapeDistTraveled
+			for j := prev + 1; j < i; j++ {
+				fraction := float64(j-prev) / float64(i-prev)
+				if useDistance {
+					fraction = (tripStopTimes[j].ShapeDistTraveled - from.ShapeDistTraveled) /
+						(to.ShapeDistTraveled - from.ShapeDistTraveled)
+					fraction = math.Min(math.Max(fraction, 0), 1)
+				}
+				t := from.DepartureTime + Time(float64(span)*fraction)
+				tripStopTimes[j].ArrivalTime = t
+				tripStopTimes[j].DepartureTime = t
+			}
+		}
+		prev = i
+	}
+}
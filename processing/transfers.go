@@ -0,0 +1,153 @@
+package processing
+
+// Values of transfer_type in transfers.txt.
+const (
+	TransferRecommended      = 0
+	TransferTimed            = 1
+	TransferMinTime          = 2
+	TransferNotPossible      = 3
+	TransferInSeat           = 4
+	TransferInSeatNotAllowed = 5
+)
+
+const (
+	// WalkSpeed is the walking speed assumed for transfers and for
+	// reaching stops, in meters per second.
+	WalkSpeed = 1.2
+	// transferRadius is how far apart two stops may be for a generated
+	// walking transfer between them.
+	transferRadius = 400.0
+)
+
+// TransferIndex holds the transfer rules of a feed by the stop they start
+// from. Stop pairs that transfers.txt does not cover, or every pair when
+// the feed has no transfers.txt, get a generated walking transfer when the
+// stops are within transferRadius of each other.
+type TransferIndex struct {
+	byFromStop map[string][]Transfer
+	parents    map[string]string
+	children   map[string][]string
+}
+
+// NewTransferIndex indexes the feed's transfers and generates walking
+// transfers between nearby stops and platforms (location_type 0).
+func NewTransferIndex(transfers []Transfer, stops []Stop, stopIndex *StopIndex) *TransferIndex {
+	index := &TransferIndex{
+		byFromStop: make(map[string][]Transfer),
+		parents:    make(map[string]string),
+		children:   make(map[string][]string),
+	}
+	for _, stop := range stops {
+		if stop.ParentStation != "" {
+			index.parents[stop.StopID] = stop.ParentStation
+			index.children[stop.ParentStation] = append(index.children[stop.ParentStation], stop.StopID)
+		}
+	}
+
+	covered := make(map[[2]string]bool)
+	for _, transfer := range transfers {
+		index.byFromStop[transfer.FromStopID] = append(index.byFromStop[transfer.FromStopID], transfer)
+		if transfer.FromRouteID == "" && transfer.ToRouteID == "" && transfer.FromTripID == "" && transfer.ToTripID == "" {
+			for _, from := range index.expand(transfer.FromStopID) {
+				for _, to := range index.expand(transfer.ToStopID) {
+					covered[[2]string{from, to}] = true
+				}
+			}
+		}
+	}
+
+	for _, stop := range stops {
+		if stop.LocationType != 0 || (stop.StopLat == 0 && stop.StopLon == 0) {
+			continue
+		}
+		for _, nearby := range stopIndex.Nearby(stop.StopLat, stop.StopLon, transferRadius, 0) {
+			if nearby.StopID == stop.StopID || covered[[2]string{stop.StopID, nearby.StopID}] {
+				continue
+			}
+			index.byFromStop[stop.StopID] = append(index.byFromStop[stop.StopID], Transfer{
+				FromStopID:      stop.StopID,
+				ToStopID:        nearby.StopID,
+				TransferType:    TransferMinTime,
+				MinTransferTime: int(WalkDuration(nearby.Distance)),
+				Generated:       true,
+			})
+		}
+	}
+	return index
+}
+
+// expand returns the stops a transfer endpoint stands for: a station's
+// platforms and the station itself, or just the stop.
+func (ix *TransferIndex) expand(stopID string) []string {
+	return append([]string{stopID}, ix.children[stopID]...)
+}
+
+// From returns the transfers listed from stopID, including generated
+// walking transfers.
+func (ix *TransferIndex) From(stopID string) []Transfer {
+	return ix.byFromStop[stopID]
+}
+
+// Applying returns the transfers that apply when leaving stopID: its own and
+// those listed from its parent station.
+func (ix *TransferIndex) Applying(stopID string) []Transfer {
+	transfers := ix.byFromStop[stopID]
+	if parent, found := ix.parents[stopID]; found {
+		transfers = append(transfers[:len(transfers):len(transfers)], ix.byFromStop[parent]...)
+	}
+	return transfers
+}
+
+// Destinations returns the stops a transfer leads to: the platforms of a
+// station, or the stop itself.
+func (ix *TransferIndex) Destinations(transfer Transfer) []string {
+	if children := ix.children[transfer.ToStopID]; len(children) > 0 {
+		return children
+	}
+	return []string{transfer.ToStopID}
+}
+
+// Between returns the rule for changing from one trip to another between two
+// stops, choosing the most specific as transfers.txt defines it: a rule
+// naming both trips, then a trip and a route, then one trip, then both
+// routes, then one route, then the stops alone. Rules for a parent station
+// apply to its platforms.
+func (ix *TransferIndex) Between(fromStopID, toStopID, fromRouteID, toRouteID, fromTripID, toTripID string) (Transfer, bool) {
+	var best Transfer
+	bestScore := -1
+	for _, transfer := range ix.Applying(fromStopID) {
+		if transfer.ToStopID != toStopID && ix.parents[toStopID] != transfer.ToStopID {
+			continue
+		}
+		score := 0
+		matches := true
+		for _, field := range []struct {
+			rule, value string
+			weight      int
+		}{
+			{transfer.FromTripID, fromTripID, 10},
+			{transfer.ToTripID, toTripID, 10},
+			{transfer.FromRouteID, fromRouteID, 3},
+			{transfer.ToRouteID, toRouteID, 3},
+		} {
+			if field.rule == "" {
+				continue
+			}
+			if field.rule != field.value {
+				matches = false
+				break
+			}
+			score += field.weight
+		}
+		if !matches {
+			continue
+		}
+		if transfer.FromStopID == fromStopID {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = transfer, score
+		}
+	}
+	return best, bestScore >= 0
+}
@@ -0,0 +1,370 @@
+package validator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"probable-system/main.go/processing"
+)
+
+// maxSamples is how many offending rows are kept for each issue.
+const maxSamples = 5
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is one kind of problem found in a feed, with how often it occurred
+// and a few of the rows that caused it.
+type Issue struct {
+	Code     string        `json:"code"`
+	Severity string        `json:"severity"`
+	Message  string        `json:"message"`
+	Count    int           `json:"count"`
+	Samples  []interface{} `json:"samples,omitempty"`
+}
+
+// Report is the result of validating a feed.
+type Report struct {
+	Counts       map[string]int `json:"counts"`
+	ErrorCount   int            `json:"error_count"`
+	WarningCount int            `json:"warning_count"`
+	Errors       []*Issue       `json:"errors"`
+	Warnings     []*Issue       `json:"warnings"`
+
+	issues map[string]*Issue
+}
+
+// Valid reports whether the feed had no errors. Warnings do not count.
+func (r *Report) Valid() bool {
+	return r.ErrorCount == 0
+}
+
+func (r *Report) add(severity, code, message string, sample interface{}) {
+	issue, found := r.issues[code]
+	if !found {
+		issue = &Issue{Code: code, Severity: severity, Message: message}
+		r.issues[code] = issue
+		if severity == SeverityError {
+			r.Errors = append(r.Errors, issue)
+		} else {
+			r.Warnings = append(r.Warnings, issue)
+		}
+	}
+	issue.Count++
+	if severity == SeverityError {
+		r.ErrorCount++
+	} else {
+		r.WarningCount++
+	}
+	if sample != nil && len(issue.Samples) < maxSamples {
+		issue.Samples = append(issue.Samples, sample)
+	}
+}
+
+// Print writes a plain text summary of the report.
+func (r *Report) Print(w io.Writer) {
+	names := make([]string, 0, len(r.Counts))
+	for name := range r.Counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%-16s %d\n", name, r.Counts[name])
+	}
+	fmt.Fprintf(w, "\n%d errors, %d warnings\n", r.ErrorCount, r.WarningCount)
+	for _, issue := range append(r.Errors, r.Warnings...) {
+		fmt.Fprintf(w, "\n[%s] %s (%d): %s\n", issue.Severity, issue.Code, issue.Count, issue.Message)
+		for _, sample := range issue.Samples {
+			fmt.Fprintf(w, "    %+v\n", sample)
+		}
+	}
+}
+
+// Validate checks the referential integrity of a loaded feed: that trips
+// point at known routes, services and shapes, that stop times point at
+// known trips and stops with increasing stop_sequence and times, that
+// transfers point at known stops, routes and trips, that frequencies point
+// at known trips with a positive headway over a non-empty window, that fare
+// rules point at known fares, routes, zones, products and stops, that the
+// station hierarchy, pathways and levels are consistent, and that every
+// entity is used. Metadata warnings are evaluated as of today.
+func Validate(feed *processing.Feed, today time.Time) *Report {
+	report := &Report{
+		Counts: map[string]int{
+			"agencies":       len(feed.Agencies),
+			"routes":         len(feed.Routes),
+			"trips":          len(feed.Trips),
+			"stops":          len(feed.Stops),
+			"stop_times":     len(feed.StopTimes),
+			"shape_points":   len(feed.Shapes),
+			"calendars":      len(feed.Calendars),
+			"calendar_dates": len(feed.CalendarDates),
+			"transfers":      len(feed.Transfers),
+			"frequencies":    len(feed.Frequencies),
+			"fares":          len(feed.FareAttributes),
+			"fare_rules":     len(feed.FareRules),
+			"fare_products":  len(feed.FareProducts),
+			"fare_leg_rules": len(feed.FareLegRules),
+			"pathways":       len(feed.Pathways),
+			"levels":         len(feed.Levels),
+		},
+		Errors:   []*Issue{},
+		Warnings: []*Issue{},
+		issues:   make(map[string]*Issue),
+	}
+
+	for _, name := range feed.MissingRequired() {
+		report.add(SeverityError, "missing_required_file", "A required GTFS file is missing", name)
+	}
+	for _, warning := range feed.ValidityWarnings(today) {
+		report.add(SeverityWarning, "feed_metadata", "Feed metadata is inconsistent or out of date", warning)
+	}
+
+	agencies := make(map[string]bool)
+	for _, agency := range feed.Agencies {
+		agencies[agency.AgencyID] = true
+	}
+
+	routes := make(map[string]bool)
+	for _, route := range feed.Routes {
+		if routes[route.RouteID] {
+			report.add(SeverityError, "duplicate_route_id", "route_id appears more than once in routes.txt", route)
+		}
+		routes[route.RouteID] = true
+		if len(feed.Agencies) > 1 && !agencies[route.AgencyID] {
+			report.add(SeverityError, "unknown_agency", "Route references an agency_id not in agency.txt", route)
+		}
+	}
+
+	withoutLocation := make(map[string]bool, len(feed.StopsWithoutLocation))
+	for _, stopID := range feed.StopsWithoutLocation {
+		withoutLocation[stopID] = true
+	}
+	stops := make(map[string]processing.Stop)
+	for _, stop := range feed.Stops {
+		if _, found := stops[stop.StopID]; found {
+			report.add(SeverityError, "duplicate_stop_id", "stop_id appears more than once in stops.txt", stop)
+		}
+		stops[stop.StopID] = stop
+		if stop.LocationType <= 2 && withoutLocation[stop.StopID] {
+			report.add(SeverityError, "missing_stop_location", "Stop, station or entrance has no coordinates", stop)
+		}
+	}
+	for _, stop := range feed.Stops {
+		if stop.ParentStation == "" {
+			continue
+		}
+		parent, found := stops[stop.ParentStation]
+		if !found {
+			report.add(SeverityError, "unknown_parent_station", "Stop references a parent_station not in stops.txt", stop)
+			continue
+		}
+		if inParentCycle(stops, stop.StopID) {
+			report.add(SeverityError, "parent_station_cycle", "Stop is its own parent_station, directly or through other stops", stop)
+			continue
+		}
+		// Boarding areas belong to a platform, every other location to a
+		// station.
+		wantParent := processing.LocationStation
+		if stop.LocationType == processing.LocationBoardingArea {
+			wantParent = processing.LocationStop
+		}
+		if stop.LocationType == processing.LocationStation || parent.LocationType != wantParent {
+			report.add(SeverityError, "invalid_parent_station", "Stop has a parent_station of the wrong location_type", stop)
+		}
+	}
+
+	services := make(map[string]bool)
+	for _, calendar := range feed.Calendars {
+		services[calendar.ServiceID] = true
+	}
+	for _, calendarDate := range feed.CalendarDates {
+		services[calendarDate.ServiceID] = true
+	}
+
+	shapes := make(map[string]bool)
+	for _, shape := range feed.Shapes {
+		shapes[shape.ShapeID] = true
+	}
+
+	trips := make(map[string]bool)
+	routesWithTrips := make(map[string]bool)
+	for _, trip := range feed.Trips {
+		if trips[trip.TripID] {
+			report.add(SeverityError, "duplicate_trip_id", "trip_id appears more than once in trips.txt", trip)
+		}
+		trips[trip.TripID] = true
+		routesWithTrips[trip.RouteID] = true
+		if !routes[trip.RouteID] {
+			report.add(SeverityError, "unknown_route", "Trip references a route_id not in routes.txt", trip)
+		}
+		if !services[trip.ServiceID] {
+			report.add(SeverityError, "unknown_service", "Trip references a service_id not in calendar.txt or calendar_dates.txt", trip)
+		}
+		if trip.ShapeID != "" && !shapes[trip.ShapeID] {
+			report.add(SeverityError, "unknown_shape", "Trip references a shape_id with no points in shapes.txt", trip)
+		}
+	}
+
+	stopTimesByTrip := make(map[string][]processing.StopTime)
+	stopsWithStopTimes := make(map[string]bool)
+	for _, stopTime := range feed.StopTimes {
+		stopTimesByTrip[stopTime.TripID] = append(stopTimesByTrip[stopTime.TripID], stopTime)
+		stopsWithStopTimes[stopTime.StopID] = true
+		if !trips[stopTime.TripID] {
+			report.add(SeverityError, "unknown_trip", "Stop time references a trip_id not in trips.txt", stopTime)
+		}
+		if _, found := stops[stopTime.StopID]; !found {
+			report.add(SeverityError, "unknown_stop", "Stop time references a stop_id not in stops.txt", stopTime)
+		}
+	}
+	for _, tripStopTimes := range stopTimesByTrip {
+		sort.SliceStable(tripStopTimes, func(i, j int) bool {
+			return tripStopTimes[i].StopSequence < tripStopTimes[j].StopSequence
+		})
+		lastTime := tripStopTimes[0].DepartureTime
+		for i := 1; i < len(tripStopTimes); i++ {
+			if tripStopTimes[i].StopSequence == tripStopTimes[i-1].StopSequence {
+				report.add(SeverityError, "duplicate_stop_sequence", "Trip has two stop times with the same stop_sequence", tripStopTimes[i])
+			}
+			if tripStopTimes[i].ArrivalTime.IsSet() && lastTime.IsSet() && tripStopTimes[i].ArrivalTime < lastTime {
+				report.add(SeverityError, "decreasing_stop_time", "Stop time arrives before the previous stop departs", tripStopTimes[i])
+			}
+			if tripStopTimes[i].DepartureTime.IsSet() {
+				lastTime = tripStopTimes[i].DepartureTime
+			}
+		}
+		if len(tripStopTimes) < 2 {
+			report.add(SeverityError, "too_few_stop_times", "Trip has fewer than two stop times", tripStopTimes[0])
+		}
+		last := tripStopTimes[len(tripStopTimes)-1]
+		if !tripStopTimes[0].DepartureTime.IsSet() || !last.ArrivalTime.IsSet() {
+			report.add(SeverityError, "missing_trip_edge_time", "First and last stop times of a trip must have times", tripStopTimes[0])
+		}
+	}
+
+	for _, transfer := range feed.Transfers {
+		_, fromFound := stops[transfer.FromStopID]
+		_, toFound := stops[transfer.ToStopID]
+		if (transfer.FromStopID != "" && !fromFound) || (transfer.ToStopID != "" && !toFound) {
+			report.add(SeverityError, "unknown_transfer_stop", "Transfer references a stop_id not in stops.txt", transfer)
+		}
+		if (transfer.FromRouteID != "" && !routes[transfer.FromRouteID]) || (transfer.ToRouteID != "" && !routes[transfer.ToRouteID]) {
+			report.add(SeverityError, "unknown_transfer_route", "Transfer references a route_id not in routes.txt", transfer)
+		}
+		if (transfer.FromTripID != "" && !trips[transfer.FromTripID]) || (transfer.ToTripID != "" && !trips[transfer.ToTripID]) {
+			report.add(SeverityError, "unknown_transfer_trip", "Transfer references a trip_id not in trips.txt", transfer)
+		}
+	}
+
+	for _, frequency := range feed.Frequencies {
+		if !trips[frequency.TripID] {
+			report.add(SeverityError, "unknown_frequency_trip", "Frequency references a trip_id not in trips.txt", frequency)
+		}
+		if frequency.HeadwaySecs <= 0 || frequency.EndTime <= frequency.StartTime {
+			report.add(SeverityError, "invalid_frequency", "Frequency needs a positive headway_secs and an end_time after its start_time", frequency)
+		}
+		if frequency.ExactTimes != 0 && frequency.ExactTimes != 1 {
+			report.add(SeverityError, "invalid_exact_times", "exact_times must be 0 or 1", frequency)
+		}
+	}
+
+	fares := make(map[string]bool)
+	for _, attribute := range feed.FareAttributes {
+		if fares[attribute.FareID] {
+			report.add(SeverityError, "duplicate_fare_id", "fare_id appears more than once in fare_attributes.txt", attribute)
+		}
+		fares[attribute.FareID] = true
+	}
+	zones := make(map[string]bool)
+	for _, stop := range feed.Stops {
+		zones[stop.ZoneID] = true
+	}
+	for _, rule := range feed.FareRules {
+		if !fares[rule.FareID] {
+			report.add(SeverityError, "unknown_fare", "Fare rule references a fare_id not in fare_attributes.txt", rule)
+		}
+		if rule.RouteID != "" && !routes[rule.RouteID] {
+			report.add(SeverityError, "unknown_fare_route", "Fare rule references a route_id not in routes.txt", rule)
+		}
+		if (rule.OriginID != "" && !zones[rule.OriginID]) || (rule.DestinationID != "" && !zones[rule.DestinationID]) || (rule.ContainsID != "" && !zones[rule.ContainsID]) {
+			report.add(SeverityError, "unknown_fare_zone", "Fare rule references a zone_id no stop has", rule)
+		}
+	}
+	products := make(map[string]bool)
+	for _, product := range feed.FareProducts {
+		products[product.FareProductID] = true
+	}
+	for _, rule := range feed.FareLegRules {
+		if !products[rule.FareProductID] {
+			report.add(SeverityError, "unknown_fare_product", "Fare leg rule references a fare_product_id not in fare_products.txt", rule)
+		}
+	}
+	for _, stopArea := range feed.StopAreas {
+		if _, found := stops[stopArea.StopID]; !found {
+			report.add(SeverityError, "unknown_area_stop", "Stop area references a stop_id not in stops.txt", stopArea)
+		}
+	}
+
+	levels := make(map[string]bool)
+	for _, level := range feed.Levels {
+		levels[level.LevelID] = true
+	}
+	if len(feed.Levels) > 0 {
+		for _, stop := range feed.Stops {
+			if stop.LevelID != "" && !levels[stop.LevelID] {
+				report.add(SeverityError, "unknown_level", "Stop references a level_id not in levels.txt", stop)
+			}
+		}
+	}
+	for _, pathway := range feed.Pathways {
+		from, fromFound := stops[pathway.FromStopID]
+		to, toFound := stops[pathway.ToStopID]
+		if !fromFound || !toFound {
+			report.add(SeverityError, "unknown_pathway_stop", "Pathway references a stop_id not in stops.txt", pathway)
+		} else if from.LocationType == processing.LocationStation || to.LocationType == processing.LocationStation {
+			report.add(SeverityError, "pathway_to_station", "Pathway must join platforms, entrances, nodes or boarding areas, not a station", pathway)
+		}
+		if processing.PathwayModeName(pathway.PathwayMode) == "unknown" {
+			report.add(SeverityError, "invalid_pathway_mode", "pathway_mode must be 1 to 7", pathway)
+		}
+	}
+
+	if len(feed.StopTimes) > 0 {
+		for _, trip := range feed.Trips {
+			if _, found := stopTimesByTrip[trip.TripID]; !found {
+				report.add(SeverityWarning, "trip_without_stop_times", "Trip has no stop times", trip)
+			}
+		}
+		for _, stop := range feed.Stops {
+			if stop.LocationType == 0 && !stopsWithStopTimes[stop.StopID] {
+				report.add(SeverityWarning, "unused_stop", "Stop is not served by any stop time", stop)
+			}
+		}
+	}
+	for _, route := range feed.Routes {
+		if !routesWithTrips[route.RouteID] {
+			report.add(SeverityWarning, "unused_route", "Route has no trips", route)
+		}
+	}
+
+	return report
+}
+
+// inParentCycle reports whether following parent_station from stopID leads
+// back to stopID.
+func inParentCycle(stops map[string]processing.Stop, stopID string) bool {
+	visited := make(map[string]bool)
+	for parentID := stops[stopID].ParentStation; parentID != "" && !visited[parentID]; {
+		if parentID == stopID {
+			return true
+		}
+		visited[parentID] = true
+		parentID = stops[parentID].ParentStation
+	}
+	return false
+}
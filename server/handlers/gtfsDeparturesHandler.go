@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"probable-system/main.go/processing"
+	"probable-system/main.go/server/services/transportation"
+)
+
+const (
+	defaultDepartureLimit = 10
+	maxDepartureLimit     = 50
+	// departureLookback is how far before now scheduled departures are
+	// still considered, as a late vehicle may not have left yet.
+	departureLookback = 30 * time.Minute
+	// onTimeMargin is how far a prediction may be from the schedule and
+	// still count as on time.
+	onTimeMargin = time.Minute
+)
+
+// departureCandidate is a scheduled departure from the stop on one service
+// date.
+type departureCandidate struct {
+	stopTime    *processing.StopTime
+	trip        processing.Trip
+	serviceDate time.Time
+	scheduled   time.Time
+}
+
+// scheduledDepartures returns the departures from the stop stopId on
+// serviceDate that are scheduled from since on, at most limit of them after
+// until. The last stop of a trip and stops without pickup are not
+// departures.
+func (d *StaticData) scheduledDepartures(stopId string, serviceDate time.Time, since time.Time, until time.Time, limit int) []departureCandidate {
+	stopTimes := d.StopDepartures[stopId]
+	from := processing.TimeOf(since, serviceDate, d.Location)
+	first := sort.Search(len(stopTimes), func(i int) bool {
+		return stopTimes[i].DepartureTime >= from
+	})
+
+	var candidates []departureCandidate
+	upcoming := 0
+	for _, stopTime := range stopTimes[first:] {
+		if stopTime.PickupType == 1 {
+			continue
+		}
+		trip, found := d.findTripById(stopTime.TripID)
+		if !found || !d.ServiceCalendar.IsActive(trip.ServiceID, serviceDate) {
+			continue
+		}
+		tripStopTimes := d.StopTimes[trip.TripID]
+		if tripStopTimes[len(tripStopTimes)-1].StopSequence == stopTime.StopSequence {
+			continue
+		}
+		// Only departures that pass the filters above count against limit.
+		scheduled := stopTime.DepartureTime.On(serviceDate, d.Location)
+		if !scheduled.Before(until) {
+			if upcoming >= limit {
+				break
+			}
+			upcoming++
+		}
+		candidates = append(candidates, departureCandidate{
+			stopTime:    stopTime,
+			trip:        trip,
+			serviceDate: serviceDate,
+			scheduled:   scheduled,
+		})
+	}
+	return candidates
+}
+
+// stopTimeIndex returns the position of stopTime within its trip.
+func (d *StaticData) stopTimeIndex(stopTime *processing.StopTime) int {
+	for i, tripStopTime := range d.StopTimes[stopTime.TripID] {
+		if tripStopTime.StopSequence == stopTime.StopSequence {
+			return i
+		}
+	}
+	return -1
+}
+
+func HandleStopDepartures(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, id, ok := requestFeed(w, r, id)
+	if !ok {
+		return
+	}
+	data := feed.Data()
+
+	stop, found := data.findStopById(id)
+	if !found {
+		http.Error(w, `{"error": "Stop not found"}`, http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultDepartureLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxDepartureLimit {
+			http.Error(w, `{"error": "Invalid limit, expected 1 to 50"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	now := time.Now().In(data.Location)
+	if value := query.Get("at"); value != "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid at, expected an RFC 3339 time"}`, http.StatusBadRequest)
+			return
+		}
+		now = at.In(data.Location)
+	}
+
+	// A station's departures are those of its platforms.
+	stopIds := append([]string{stop.StopID}, data.StopChildren[stop.StopID]...)
+
+	// Trips of yesterday's service day may still be running after midnight,
+	// and tomorrow's may be the next to leave late at night.
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, data.Location)
+	var candidates []departureCandidate
+	for _, offset := range []int{-1, 0, 1} {
+		serviceDate := today.AddDate(0, 0, offset)
+		for _, stopId := range stopIds {
+			candidates = append(candidates, data.scheduledDepartures(stopId, serviceDate, now.Add(-departureLookback), now, limit)...)
+		}
+	}
+
+	realtime := map[string]interface{}{
+		"available": false,
+	}
+	var updates transportation.TripUpdates
+	if feed.tripUpdates != nil {
+		snapshot, err := feed.tripUpdates.Latest(r.Context())
+		if err != nil {
+			fmt.Println("Error fetching GTFS-RT trip updates:", err)
+			realtime["error"] = err.Error()
+		} else {
+			updates = transportation.IndexTripUpdates(snapshot.Message)
+			realtime = realtimeStatus(snapshot, data.Location)
+			realtime["available"] = true
+		}
+	}
+
+	type departure struct {
+		TripID             string     `json:"trip_id"`
+		RouteID            string     `json:"route_id"`
+		RouteShortName     string     `json:"route_short_name"`
+		RouteColor         string     `json:"route_color"`
+		Headsign           string     `json:"headsign"`
+		StopID             string     `json:"stop_id"`
+		PlatformCode       string     `json:"platform_code,omitempty"`
+		StopSequence       int        `json:"stop_sequence"`
+		ServiceDate        string     `json:"service_date"`
+		ScheduledDeparture time.Time  `json:"scheduled_departure"`
+		PredictedDeparture *time.Time `json:"predicted_departure"`
+		DelaySeconds       *int       `json:"delay_seconds"`
+		Status             string     `json:"status"`
+		Realtime           bool       `json:"realtime"`
+		HeadwaySecs        int        `json:"headway_secs,omitempty"`
+		leaves             time.Time
+	}
+
+	// The run of each trip nearest to now is the one an update without a
+	// start date refers to.
+	currentRuns := make(map[string]departureCandidate)
+	for _, candidate := range candidates {
+		current, found := currentRuns[candidate.trip.TripID]
+		if !found || absDuration(candidate.scheduled.Sub(now)) < absDuration(current.scheduled.Sub(now)) {
+			currentRuns[candidate.trip.TripID] = candidate
+		}
+	}
+
+	departures := []departure{}
+	for _, candidate := range candidates {
+		route, _ := data.findRouteByID(candidate.trip.RouteID)
+		platform, _ := data.findStopById(candidate.stopTime.StopID)
+		headsign := candidate.stopTime.StopHeadsign
+		if headsign == "" {
+			headsign = candidate.trip.TripHeadsign
+		}
+		serviceDate := candidate.serviceDate.Format(processing.DateLayout)
+		next := departure{
+			TripID:             candidate.trip.TripID,
+			RouteID:            route.RouteID,
+			RouteShortName:     route.RouteShortName,
+			RouteColor:         route.RouteColor,
+			Headsign:           headsign,
+			StopID:             candidate.stopTime.StopID,
+			PlatformCode:       platform.PlatformCode,
+			StopSequence:       candidate.stopTime.StopSequence,
+			ServiceDate:        serviceDate,
+			ScheduledDeparture: candidate.scheduled,
+			Status:             "scheduled",
+			HeadwaySecs:        data.FrequencyRuns[candidate.trip.TripID].HeadwaySecs,
+		}
+
+		next.leaves = candidate.scheduled
+		currentDate := currentRuns[candidate.trip.TripID].serviceDate.Format(processing.DateLayout)
+		if update, found := updates.Find(candidate.trip.TripID, serviceDate, currentDate); found {
+			next.Realtime = true
+			index := data.stopTimeIndex(candidate.stopTime)
+			prediction := transportation.Predict(update, data.StopTimes[candidate.trip.TripID], index, candidate.serviceDate, data.Location)
+			switch {
+			case prediction.Canceled:
+				next.Status = "canceled"
+			case prediction.Skipped:
+				next.Status = "skipped"
+			case prediction.HasEstimate():
+				predicted := prediction.Departure
+				delay := int(prediction.Delay / time.Second)
+				next.PredictedDeparture, next.DelaySeconds = &predicted, &delay
+				next.leaves = predicted
+				switch {
+				case prediction.Delay >= onTimeMargin:
+					next.Status = "delayed"
+				case prediction.Delay <= -onTimeMargin:
+					next.Status = "early"
+				default:
+					next.Status = "on_time"
+				}
+			}
+		}
+		if next.leaves.Before(now) {
+			continue
+		}
+		departures = append(departures, next)
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].leaves.Before(departures[j].leaves)
+	})
+	if len(departures) > limit {
+		departures = departures[:limit]
+	}
+
+	response := map[string]interface{}{
+		"feed_id":    data.FeedID,
+		"stop":       stop,
+		"now":        now,
+		"realtime":   realtime,
+		"departures": departures,
+	}
+	writeJSON(w, response)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
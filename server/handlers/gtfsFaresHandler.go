@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"probable-system/main.go/processing"
+)
+
+// fareStop reads a stop ID, with or without this feed's prefix, and returns
+// the stop with the IDs a ride may use there: the stop, and the platforms
+// of a station.
+func (d *StaticData) fareStop(value string) (processing.Stop, []string, error) {
+	if feedID, localID, found := splitGlobalID(value); found {
+		if feedID != d.FeedID {
+			return processing.Stop{}, nil, fmt.Errorf("stop %s belongs to another feed", value)
+		}
+		value = localID
+	}
+	stop, found := d.findStopById(value)
+	if !found {
+		return processing.Stop{}, nil, fmt.Errorf("stop %s not found", value)
+	}
+	return stop, append([]string{stop.StopID}, d.StopChildren[stop.StopID]...), nil
+}
+
+// stopZone returns the fare zone of a stop, or of its station when the stop
+// has none.
+func (d *StaticData) stopZone(stopId string) string {
+	stop, _ := d.findStopById(stopId)
+	if stop.ZoneID == "" && stop.ParentStation != "" {
+		parent, _ := d.findStopById(stop.ParentStation)
+		return parent.ZoneID
+	}
+	return stop.ZoneID
+}
+
+// fareRides returns the distinct rides from one of fromIds to one of toIds
+// that a trip makes, optionally only on the route routeId. Rides are
+// distinct by route, stops and zones passed through.
+func (d *StaticData) fareRides(fromIds []string, toIds []string, routeId string) []processing.FareRide {
+	var rides []processing.FareRide
+	seen := make(map[string]bool)
+	for _, fromId := range fromIds {
+		for _, stopTime := range d.StopDepartures[fromId] {
+			trip, found := d.findTripById(stopTime.TripID)
+			if !found || (routeId != "" && trip.RouteID != routeId) || stopTime.PickupType == 1 {
+				continue
+			}
+			tripStopTimes := d.StopTimes[trip.TripID]
+			board := d.stopTimeIndex(stopTime)
+			for alight := board + 1; alight < len(tripStopTimes); alight++ {
+				if tripStopTimes[alight].DropOffType == 1 || !slices.Contains(toIds, tripStopTimes[alight].StopID) {
+					continue
+				}
+				route, _ := d.findRouteByID(trip.RouteID)
+				ride := processing.FareRide{
+					RouteID:    route.RouteID,
+					AgencyID:   route.AgencyID,
+					NetworkID:  route.NetworkID,
+					FromStopID: fromId,
+					ToStopID:   tripStopTimes[alight].StopID,
+				}
+				for _, ridden := range tripStopTimes[board : alight+1] {
+					ride.Zones = append(ride.Zones, d.stopZone(ridden.StopID))
+				}
+				key := strings.Join(append([]string{ride.RouteID, ride.FromStopID, ride.ToStopID}, ride.Zones...), "\x00")
+				if !seen[key] {
+					seen[key] = true
+					rides = append(rides, ride)
+				}
+				break
+			}
+		}
+	}
+	return rides
+}
+
+func HandleFareEstimate(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+	if !data.Fares.HasFares() {
+		http.Error(w, `{"error": "Feed has no fares"}`, http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	from, fromIds, err := data.fareStop(query.Get("from"))
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	to, toIds, err := data.fareStop(query.Get("to"))
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Invalid to: " + err.Error()})
+		return
+	}
+	routeId := query.Get("route")
+	if routeId != "" {
+		if _, found := data.findRouteByID(routeId); !found {
+			http.Error(w, `{"error": "Route not found"}`, http.StatusNotFound)
+			return
+		}
+	}
+
+	rides := data.fareRides(fromIds, toIds, routeId)
+	if len(rides) == 0 {
+		http.Error(w, `{"error": "No trip runs from the origin to the destination"}`, http.StatusNotFound)
+		return
+	}
+
+	type estimate struct {
+		RouteID        string            `json:"route_id"`
+		RouteShortName string            `json:"route_short_name"`
+		FromStopID     string            `json:"from_stop_id"`
+		ToStopID       string            `json:"to_stop_id"`
+		Zones          []string          `json:"zones"`
+		Fare           *processing.Fare  `json:"fare"`
+		Fares          []processing.Fare `json:"fares"`
+	}
+
+	estimates := []estimate{}
+	for _, ride := range rides {
+		route, _ := data.findRouteByID(ride.RouteID)
+		next := estimate{
+			RouteID:        route.RouteID,
+			RouteShortName: route.RouteShortName,
+			FromStopID:     ride.FromStopID,
+			ToStopID:       ride.ToStopID,
+			Zones:          ride.Zones,
+			Fares:          data.Fares.Ride(ride),
+		}
+		if len(next.Fares) > 0 {
+			next.Fare = &next.Fares[0]
+		} else {
+			next.Fares = []processing.Fare{}
+		}
+		estimates = append(estimates, next)
+	}
+	// Rides with a fare come first, cheapest first.
+	sort.SliceStable(estimates, func(i, j int) bool {
+		if (estimates[i].Fare == nil) != (estimates[j].Fare == nil) {
+			return estimates[i].Fare != nil
+		}
+		return estimates[i].Fare != nil && estimates[i].Fare.Price < estimates[j].Fare.Price
+	})
+
+	response := map[string]interface{}{
+		"feed_id":   data.FeedID,
+		"from":      from,
+		"to":        to,
+		"fare":      estimates[0].Fare,
+		"estimates": estimates,
+	}
+	writeJSON(w, response)
+}
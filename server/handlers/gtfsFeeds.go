@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"probable-system/main.go/processing"
+	"probable-system/main.go/server/services/transportation"
+)
+
+// feedState is one registered feed: its config, the static data loaded for
+// it, and the pollers of its GTFS-RT feeds, nil for those it does not
+// publish. reloadMu serialises reloads of the feed so two of them never
+// race to swap the data.
+type feedState struct {
+	config           transportation.FeedConfig
+	data             atomic.Pointer[StaticData]
+	reloadMu         sync.Mutex
+	alerts           *transportation.FeedPoller
+	tripUpdates      *transportation.FeedPoller
+	vehiclePositions *transportation.FeedPoller
+}
+
+// realtimePollers returns the feed's GTFS-RT pollers, leaving out those it
+// does not publish.
+func (f *feedState) realtimePollers() []*transportation.FeedPoller {
+	var pollers []*transportation.FeedPoller
+	for _, poller := range []*transportation.FeedPoller{f.alerts, f.tripUpdates, f.vehiclePositions} {
+		if poller != nil {
+			pollers = append(pollers, poller)
+		}
+	}
+	return pollers
+}
+
+// Data returns the static data in use for the feed. Handlers take it once
+// per request and read everything from the returned value.
+func (f *feedState) Data() *StaticData {
+	return f.data.Load()
+}
+
+// feeds and feedOrder are written once by InitFeedRegistry before the
+// server starts and only read afterwards.
+var feeds = make(map[string]*feedState)
+var feedOrder []string
+var defaultFeedID string
+
+// InitFeedRegistry registers the feeds to serve, each with empty static data
+// until InitFeed or a reload fills it in.
+func InitFeedRegistry(registry *transportation.FeedRegistry) {
+	feeds = make(map[string]*feedState, len(registry.Feeds))
+	feedOrder = nil
+	for _, config := range registry.Feeds {
+		feed := &feedState{config: config}
+		alerts, tripUpdates, vehiclePositions := config.RealtimeClients()
+		feed.alerts = realtimePoller(config.ID+" alerts", alerts)
+		feed.tripUpdates = realtimePoller(config.ID+" trip updates", tripUpdates)
+		feed.vehiclePositions = realtimePoller(config.ID+" vehicle positions", vehiclePositions)
+		feed.data.Store(emptyStaticData(config.ID))
+		feeds[config.ID] = feed
+		feedOrder = append(feedOrder, config.ID)
+	}
+	defaultFeedID = registry.Default
+}
+
+func realtimePoller(name string, client *transportation.FeedClient) *transportation.FeedPoller {
+	if client == nil {
+		return nil
+	}
+	return transportation.NewFeedPoller(name, client)
+}
+
+// PollRealtimeFeeds starts polling the GTFS-RT feeds of every registered
+// feed every interval, so requests read the latest snapshot instead of
+// each fetching the feed. Without it, every request fetches the feed.
+func PollRealtimeFeeds(interval time.Duration) {
+	for _, feedID := range feedOrder {
+		for _, poller := range feeds[feedID].realtimePollers() {
+			poller.Start(interval)
+		}
+	}
+}
+
+// realtimeStatus describes the GTFS-RT snapshot a response was built from,
+// in the feed's timezone. The timestamp is left out when the feed header
+// has none.
+func realtimeStatus(snapshot transportation.FeedSnapshot, loc *time.Location) map[string]interface{} {
+	status := map[string]interface{}{
+		"fetched_at":  snapshot.FetchedAt.In(loc),
+		"age_seconds": int(snapshot.Age.Seconds()),
+		"stale":       snapshot.Stale,
+	}
+	if !snapshot.Timestamp.IsZero() {
+		status["timestamp"] = snapshot.Timestamp.In(loc)
+	}
+	if snapshot.LastError != "" {
+		status["error"] = snapshot.LastError
+		status["failures"] = snapshot.Failures
+	}
+	return status
+}
+
+// setRealtimeHeaders describes the GTFS-RT snapshot a plain response was
+// built from in its headers.
+func setRealtimeHeaders(w http.ResponseWriter, snapshot transportation.FeedSnapshot) {
+	if !snapshot.Timestamp.IsZero() {
+		w.Header().Set("X-Feed-Timestamp", snapshot.Timestamp.UTC().Format(time.RFC3339))
+	}
+	w.Header().Set("X-Feed-Fetched-At", snapshot.FetchedAt.UTC().Format(time.RFC3339))
+	w.Header().Set("X-Feed-Stale", strconv.FormatBool(snapshot.Stale))
+	w.Header().Set("Age", strconv.Itoa(int(snapshot.Age.Seconds())))
+}
+
+// InitFeed builds the lookup maps from a loaded GTFS feed and makes them the
+// current static data of the registered feed feedID.
+func InitFeed(feedID string, feed *processing.Feed) {
+	if state, found := feeds[feedID]; found {
+		state.data.Store(NewStaticData(feedID, feed))
+	}
+}
+
+// splitGlobalID splits a "feed:id" ID, e.g. "rtd:15398", when the prefix
+// names a registered feed. GTFS IDs may contain ':' themselves, so any
+// other ID is returned whole. Only IDs a request sends are namespaced this
+// way; responses give IDs as the feed publishes them beside a feed_id.
+func splitGlobalID(id string) (string, string, bool) {
+	feedID, localID, found := strings.Cut(id, ":")
+	if !found {
+		return "", id, false
+	}
+	if _, registered := feeds[feedID]; !registered {
+		return "", id, false
+	}
+	return feedID, localID, true
+}
+
+// requestFeed returns the feed a request is for, and id with any feed prefix
+// removed. The feed is taken from a "feed:" prefix on id, then the feed
+// query parameter, then the default feed. For an unknown feed it writes a
+// 404 and returns false.
+func requestFeed(w http.ResponseWriter, r *http.Request, id string) (*feedState, string, bool) {
+	feedID := r.URL.Query().Get("feed")
+	if prefix, localID, found := splitGlobalID(id); found {
+		if feedID != "" && feedID != prefix {
+			http.Error(w, `{"error": "ID belongs to a different feed than the feed parameter"}`, http.StatusBadRequest)
+			return nil, "", false
+		}
+		feedID, id = prefix, localID
+	}
+	if feedID == "" {
+		feedID = defaultFeedID
+	}
+	feed, found := feeds[feedID]
+	if !found {
+		http.Error(w, `{"error": "Feed not found"}`, http.StatusNotFound)
+		return nil, "", false
+	}
+	return feed, id, true
+}
+
+// requestData is requestFeed for handlers that only need the static data.
+func requestData(w http.ResponseWriter, r *http.Request, id string) (*StaticData, string, bool) {
+	feed, id, ok := requestFeed(w, r, id)
+	if !ok {
+		return nil, "", false
+	}
+	return feed.Data(), id, true
+}
+
+func HandleFeeds(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type feedSummary struct {
+		ID               string               `json:"id"`
+		Name             string               `json:"name"`
+		Default          bool                 `json:"default"`
+		LoadedAt         time.Time            `json:"loaded_at"`
+		FeedInfo         *processing.FeedInfo `json:"feed_info"`
+		Routes           int                  `json:"routes"`
+		Trips            int                  `json:"trips"`
+		Stops            int                  `json:"stops"`
+		Alerts           bool                 `json:"alerts"`
+		TripUpdates      bool                 `json:"trip_updates"`
+		VehiclePositions bool                 `json:"vehicle_positions"`
+	}
+
+	summaries := make([]feedSummary, 0, len(feedOrder))
+	for _, feedID := range feedOrder {
+		feed := feeds[feedID]
+		data := feed.Data()
+		summaries = append(summaries, feedSummary{
+			ID:               feedID,
+			Name:             feed.config.Name,
+			Default:          feedID == defaultFeedID,
+			LoadedAt:         data.LoadedAt,
+			FeedInfo:         data.Feed.FeedInfo,
+			Routes:           len(data.Routes),
+			Trips:            len(data.Trips),
+			Stops:            len(data.Stops),
+			Alerts:           feed.config.AlertsURL != "",
+			TripUpdates:      feed.config.TripUpdatesURL != "",
+			VehiclePositions: feed.config.VehiclePositionsURL != "",
+		})
+	}
+
+	response := map[string]interface{}{
+		"default": defaultFeedID,
+		"feeds":   summaries,
+	}
+	writeJSON(w, response)
+}
@@ -4,26 +4,109 @@ import (
 	"fmt"
 	"net/http"
 	"probable-system/main.go/processing"
-	"probable-system/main.go/processing/output"
-
 	"probable-system/main.go/server/services/transportation"
+	"slices"
+	"sort"
+	"time"
 )
 
-var RoutesMap = make(map[string]processing.Route)
-var ShapesMap = make(map[string]processing.Shape)
-var StopTimesMap = make(map[string]processing.StopTime)
-var StopsMap = make(map[string]processing.Stop)
-var TripsMap = make(map[string]processing.Trip)
+// StaticData is one loaded GTFS static feed with its lookup maps. It is
+// never modified after NewStaticData returns; a reload builds a new one and
+// swaps it in, so a request that took the previous one keeps a consistent
+// view until it finishes. IDs in the maps are the feed's own, without the
+// feed prefix.
+type StaticData struct {
+	FeedID            string
+	Feed              *processing.Feed
+	Location          *time.Location
+	Routes            map[string]processing.Route
+	Shapes            map[string][]processing.Shape
+	StopTimes         map[string][]processing.StopTime
+	StopDepartures    map[string][]*processing.StopTime
+	Stops             map[string]processing.Stop
+	StopChildren      map[string][]string
+	Trips             map[string]processing.Trip
+	FrequencyRuns     map[string]processing.FrequencyRun
+	RouteTrips        map[string][]processing.Trip
+	ServiceTripCounts map[string]int
+	ServiceCalendar   *processing.ServiceCalendar
+	StopIndex         *processing.StopIndex
+	StopSearch        *processing.StopSearchIndex
+	StopRoutes        map[string][]string
+	Transfers         *processing.TransferIndex
+	Timetable         *processing.Timetable
+	Fares             *processing.FareIndex
+	Stations          *processing.StationIndex
+	LoadedAt          time.Time
+}
+
+// NewStaticData builds the lookup maps for feed. Trips repeated by
+// frequencies.txt are indexed as their individual runs. The feed itself is
+// not modified, so it can still be validated or written out as published.
+func NewStaticData(feedID string, feed *processing.Feed) *StaticData {
+	data := &StaticData{FeedID: feedID, Feed: feed, LoadedAt: time.Now()}
+	trips, stopTimes, runs := processing.ExpandFrequencies(feed.Trips, feed.StopTimes, feed.Frequencies)
+	if len(feed.Frequencies) > 0 {
+		fmt.Print("Frequencies expanded ", len(feed.Frequencies), " headway periods, giving ", len(trips), " trips\n")
+	}
+	data.initFeedMetadata()
+	data.initRouteMap(feed.Routes)
+	data.initShapesMap(feed.Shapes)
+	data.initStopTimesMap(stopTimes)
+	data.initStopsMap(feed.Stops)
+	data.initTripsMap(trips)
+	data.FrequencyRuns = runs
+	data.initServiceCalendar(feed.Calendars, feed.CalendarDates)
+	data.initStopIndex(feed.Stops)
+	data.initTransfers(feed.Transfers, feed.Stops)
+	data.initTimetable(trips)
+	data.initFares(feed)
+	data.initStations(feed)
+	return data
+}
+
+// emptyStaticData is the data of a feed that has not loaded yet.
+func emptyStaticData(feedID string) *StaticData {
+	stopIndex := processing.NewStopIndex(nil)
+	transfers := processing.NewTransferIndex(nil, nil, stopIndex)
+	return &StaticData{
+		FeedID:          feedID,
+		Feed:            &processing.Feed{},
+		Location:        time.UTC,
+		ServiceCalendar: processing.NewServiceCalendar(nil, nil),
+		StopIndex:       stopIndex,
+		StopSearch:      processing.NewStopSearchIndex(nil),
+		Transfers:       transfers,
+		Timetable:       processing.NewTimetable(nil, nil, nil, transfers),
+		Fares:           processing.NewFareIndex(nil, nil, nil, nil, nil, nil),
+		Stations:        processing.NewStationIndex(nil, nil, nil),
+	}
+}
+
+// initFeedMetadata sets the agency timezone as the reference for schedule
+// times.
+func (d *StaticData) initFeedMetadata() {
+	loc, err := d.Feed.Location()
+	if err != nil {
+		fmt.Println("Using UTC for schedule times:", err)
+		loc = time.UTC
+	}
+	d.Location = loc
+	for _, warning := range d.Feed.ValidityWarnings(processing.Today(d.Location)) {
+		fmt.Println("GTFS feed warning:", warning)
+	}
+}
 
-func InitRouteMap() {
-	for _, route := range output.Routes {
-		RoutesMap[route.RouteID] = route
+func (d *StaticData) initRouteMap(routes []processing.Route) {
+	d.Routes = make(map[string]processing.Route, len(routes))
+	for _, route := range routes {
+		d.Routes[route.RouteID] = route
 	}
-	fmt.Print("RoutesMap initialized with ", len(RoutesMap), " routes\n")
+	fmt.Print("RoutesMap initialized with ", len(d.Routes), " routes\n")
 }
 
-func findRouteByID(routeId string) (processing.Route, bool) {
-	route, found := RoutesMap[routeId]
+func (d *StaticData) findRouteByID(routeId string) (processing.Route, bool) {
+	route, found := d.Routes[routeId]
 	if !found {
 		return processing.Route{}, false
 	} else {
@@ -32,48 +115,49 @@ func findRouteByID(routeId string) (processing.Route, bool) {
 	}
 }
 
-func InitShapesMap() {
-	for _, shape := range output.Shapes {
-		ShapesMap[shape.ShapeID] = shape
-	}
-	fmt.Print("ShapesMap initialized with ", len(ShapesMap), " shapes\n")
+func (d *StaticData) initShapesMap(shapes []processing.Shape) {
+	d.Shapes = processing.GroupShapes(shapes)
+	fmt.Print("ShapesMap initialized with ", len(d.Shapes), " shapes from ", len(shapes), " points\n")
 }
 
-func findShapeById(shapeId string) (processing.Shape, bool) {
-	shape, found := ShapesMap[shapeId]
+func (d *StaticData) findShapeById(shapeId string) ([]processing.Shape, bool) {
+	points, found := d.Shapes[shapeId]
 	if !found {
-		return processing.Shape{}, false
+		return nil, false
 	} else {
-		return shape, true
+		return points, true
 	}
 
 }
 
-func InitStopTimesMap() {
-	for _, stopTime := range output.StopTime {
-		StopTimesMap[stopTime.TripID] = stopTime
-	}
-	fmt.Print("StopTimesMap initialized with ", len(StopTimesMap), " stop times\n")
+func (d *StaticData) initStopTimesMap(stopTimes []processing.StopTime) {
+	d.StopTimes, d.StopDepartures = processing.IndexStopTimes(stopTimes)
+	fmt.Print("StopTimesMap initialized with ", len(stopTimes), " stop times for ", len(d.StopTimes), " trips\n")
 }
 
-func findStopTimeById(tripId string) (processing.StopTime, bool) {
-	stopTime, found := StopTimesMap[tripId]
+func (d *StaticData) findStopTimesByTripId(tripId string) ([]processing.StopTime, bool) {
+	stopTimes, found := d.StopTimes[tripId]
 	if !found {
-		return processing.StopTime{}, false
+		return nil, false
 	} else {
-		return stopTime, true
+		return stopTimes, true
 	}
 }
 
-func InitStopsMap() {
-	for _, stop := range output.Stop {
-		StopsMap[stop.StopID] = stop
+func (d *StaticData) initStopsMap(stops []processing.Stop) {
+	d.Stops = make(map[string]processing.Stop, len(stops))
+	d.StopChildren = make(map[string][]string)
+	for _, stop := range stops {
+		d.Stops[stop.StopID] = stop
+		if stop.ParentStation != "" {
+			d.StopChildren[stop.ParentStation] = append(d.StopChildren[stop.ParentStation], stop.StopID)
+		}
 	}
-	fmt.Print("StopsMap initialized with ", len(StopsMap), " stops\n")
+	fmt.Print("StopsMap initialized with ", len(d.Stops), " stops\n")
 }
 
-func findStopById(stopId string) (processing.Stop, bool) {
-	stop, found := StopsMap[stopId]
+func (d *StaticData) findStopById(stopId string) (processing.Stop, bool) {
+	stop, found := d.Stops[stopId]
 	if !found {
 		return processing.Stop{}, false
 	} else {
@@ -81,15 +165,20 @@ func findStopById(stopId string) (processing.Stop, bool) {
 	}
 }
 
-func InitTripsMap() {
-	for _, trip := range output.Trips {
-		TripsMap[trip.TripID] = trip
+func (d *StaticData) initTripsMap(trips []processing.Trip) {
+	d.Trips = make(map[string]processing.Trip, len(trips))
+	d.RouteTrips = make(map[string][]processing.Trip)
+	d.ServiceTripCounts = make(map[string]int)
+	for _, trip := range trips {
+		d.Trips[trip.TripID] = trip
+		d.RouteTrips[trip.RouteID] = append(d.RouteTrips[trip.RouteID], trip)
+		d.ServiceTripCounts[trip.ServiceID]++
 	}
-	fmt.Print("TripsMap initialized with ", len(TripsMap), " trips\n")
+	fmt.Print("TripsMap initialized with ", len(d.Trips), " trips\n")
 }
 
-func findTripById(tripId string) (processing.Trip, bool) {
-	trip, found := TripsMap[tripId]
+func (d *StaticData) findTripById(tripId string) (processing.Trip, bool) {
+	trip, found := d.Trips[tripId]
 	if !found {
 		return processing.Trip{}, false
 	} else {
@@ -97,112 +186,113 @@ func findTripById(tripId string) (processing.Trip, bool) {
 	}
 }
 
+// initStopIndex builds the spatial and search indexes of stops and, for each
+// stop, the IDs of the routes whose trips call there.
+func (d *StaticData) initStopIndex(stops []processing.Stop) {
+	d.StopIndex = processing.NewStopIndex(stops)
+	d.StopSearch = processing.NewStopSearchIndex(stops)
+	d.StopRoutes = make(map[string][]string, len(d.StopDepartures))
+	for stopId, stopTimes := range d.StopDepartures {
+		var routeIds []string
+		for _, stopTime := range stopTimes {
+			trip, found := d.Trips[stopTime.TripID]
+			if found && !slices.Contains(routeIds, trip.RouteID) {
+				routeIds = append(routeIds, trip.RouteID)
+			}
+		}
+		sort.Strings(routeIds)
+		d.StopRoutes[stopId] = routeIds
+	}
+	fmt.Print("StopIndex initialized with ", len(d.StopRoutes), " served stops\n")
+}
+
+func (d *StaticData) initTransfers(transfers []processing.Transfer, stops []processing.Stop) {
+	d.Transfers = processing.NewTransferIndex(transfers, stops, d.StopIndex)
+	fmt.Print("Transfers initialized with ", len(transfers), " transfers from the feed\n")
+}
+
+func (d *StaticData) initTimetable(trips []processing.Trip) {
+	d.Timetable = processing.NewTimetable(trips, d.StopTimes, d.Stops, d.Transfers)
+	fmt.Print("Timetable initialized with ", d.Timetable.Connections(), " connections\n")
+}
+
+func (d *StaticData) initFares(feed *processing.Feed) {
+	d.Fares = processing.NewFareIndex(feed.FareAttributes, feed.FareRules, feed.FareProducts, feed.FareLegRules, feed.StopAreas, feed.Stops)
+	fmt.Print("Fares initialized with ", len(feed.FareAttributes), " fares and ", len(feed.FareLegRules), " fare leg rules\n")
+}
+
+func (d *StaticData) initStations(feed *processing.Feed) {
+	d.Stations = processing.NewStationIndex(feed.Stops, feed.Pathways, feed.Levels)
+	fmt.Print("Stations initialized with ", d.Stations.Stations(), " stations and ", len(feed.Pathways), " pathways\n")
+}
+
+func (d *StaticData) initServiceCalendar(calendars []processing.Calendar, calendarDates []processing.CalendarDate) {
+	d.ServiceCalendar = processing.NewServiceCalendar(calendars, calendarDates)
+	fmt.Print("ServiceCalendar initialized with ", len(calendars), " calendars and ", len(calendarDates), " exceptions\n")
+}
+
 func HandleAlert(w http.ResponseWriter, r *http.Request) {
-	feed, err := transportation.FetchAlerts()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching GTFS-RT: %v", err), http.StatusInternalServerError)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	for _, entity := range feed.Entity {
-		if entity.Alert != nil {
-			var responseString string
-			responseString += fmt.Sprintf("Response: %v\n", entity)
-			fmt.Fprintf(w, "%s", responseString)
-		}
+	source, _, ok := requestFeed(w, r, "")
+	if !ok {
+		return
+	}
+	if source.alerts == nil {
+		http.Error(w, `{"error": "Feed has no alerts feed"}`, http.StatusNotFound)
+		return
 	}
 
-	// Response: id: "49932" alert: {active_period: {start: 1725542640 end: 1748595540
-	// 	} informed_entity: {agency_id: "RTD" route_id: "11" route_type: 3 stop_id: "15398"
-	// 	} informed_entity: {agency_id: "RTD" route_id: "11" route_type: 3 stop_id: "15411"
-	// 	} informed_entity: {agency_id: "RTD" route_id: "11" route_type: 3 stop_id: "15417"
-	// 	} informed_entity: {agency_id: "RTD" route_id: "11" route_type: 3 stop_id: "15397"
-	// 	} informed_entity: {agency_id: "RTD" route_id: "11" route_type: 3 stop_id: "15410"
-	// 	} cause:CONSTRUCTION effect:DETOUR header_text: {translation: {text: "Route 11 detoured from 7:24 AM today through Thu May 29 due to construction." language: "en"
-	// 		}
-	// 	} description_text: {translation: {text: "Affected stops:\r\nMississippi Ave & S Leyden St (#15397) (eastbound)\r\nMississippi Ave & S Monaco Pkwy (#15410) (eastbound)\r\nMississippi Ave & S Leyden St (#15398) (westbound)\r\nMississippi Ave & S Monaco Pkwy (#15411) (westbound)\r\nMississippi Ave & S Oneida St (#15417) (westbound)\r\n\nFor eastbound Route 11 get on/off buses at:\r\nTennessee Ave & S Jersey St (#16741)\r\nMississippi Ave & S Oneida St (#15416)\r\n\nFor westbound Route 11 get on/off buses at:\r\nMississippi Ave & S Quebec St (#15428)\r\nTennessee Ave & S Jersey St (#16742)" language: "en"
-	// 		}
-	// 	}
-	// }
-}
-
-func HandleTripUpdate(w http.ResponseWriter, r *http.Request) {
-	feed, err := transportation.FetchTripUpdates()
+	snapshot, err := source.alerts.Latest(r.Context())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching GTFS-RT: %v", err), http.StatusInternalServerError)
+		writeJSONStatus(w, http.StatusInternalServerError, map[string]string{"error": "Error fetching GTFS-RT: " + err.Error()})
 		return
 	}
+	setRealtimeHeaders(w, snapshot)
 
-	w.Header().Set("Content-Type", "application/json")
-	for _, entity := range feed.Entity {
-		if entity.TripUpdate != nil {
-			var responseString string
-			responseString += fmt.Sprintf("Response: %v\n", entity)
-			fmt.Fprintf(w, "%s", responseString)
-		}
+	data := source.Data()
+	response := map[string]interface{}{
+		"feed_id":  data.FeedID,
+		"header":   transportation.ConvertHeader(snapshot.Message),
+		"realtime": realtimeStatus(snapshot, data.Location),
+		"alerts":   transportation.ConvertAlerts(snapshot.Message, r.URL.Query().Get("lang")),
 	}
-
-	//  Response: id: "1743462284_115184047" trip_update: {trip: {trip_id: "115184047" route_id: "0" direction_id: 0 schedule_relationship:SCHEDULED
-	// 	} vehicle: {id: "3176E117552F580BE063DC4D1FAC4CA4" label: "9329"
-	// 	} stop_time_update: {stop_sequence: 35 stop_id: "17834" arrival: {time: 1743462056
-	// 		} departure: {time: 1743462056
-	// 		} schedule_relationship:SCHEDULED
-	// 	} stop_time_update: {stop_sequence: 36 stop_id: "34343" arrival: {time: 1743462146
-	// 		} departure: {time: 1743462146
-	// 		} schedule_relationship:SCHEDULED
-	// 	} timestamp: 1743462269
-	//  }
+	writeJSON(w, response)
 }
 
-func HandleVehiclePosition(w http.ResponseWriter, r *http.Request) {
+func HandleTripUpdate(w http.ResponseWriter, r *http.Request) {
 
-	feed, err := transportation.FetchVehiclePosition()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching GTFS-RT: %v", err), http.StatusInternalServerError)
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	for _, entity := range feed.Entity {
+	source, _, ok := requestFeed(w, r, "")
+	if !ok {
+		return
+	}
+	if source.tripUpdates == nil {
+		http.Error(w, `{"error": "Feed has no trip updates feed"}`, http.StatusNotFound)
+		return
+	}
 
-		trip, foundTrip := findTripById(*entity.Vehicle.Trip.TripId)
-		route, foundRoute := findRouteByID(*entity.Vehicle.Trip.RouteId)
-		stop, foundStop := findStopById(*entity.Vehicle.StopId)
-		if entity.Vehicle != nil {
-			var responseString string
-			if foundTrip {
-				responseString += fmt.Sprintf("Trip: %v\n", trip)
-			} else {
-				responseString += fmt.Sprintf("Trip not found for Trip ID: %s\n", *entity.Vehicle.Trip.TripId)
-			}
-			if foundRoute {
-				responseString += fmt.Sprintf("Route: %v\n", route)
-			} else {
-				responseString += fmt.Sprintf("Route not found for Route ID: %s\n", *entity.Vehicle.Trip.RouteId)
-			}
-			responseString += fmt.Sprintf("direction_id: %d\n", *entity.Vehicle.Trip.DirectionId)
-			responseString += fmt.Sprintf("schedule_relationship: %s\n", *entity.Vehicle.Trip.ScheduleRelationship)
-			responseString += fmt.Sprintf("vehicle_id: %s\n", *entity.Vehicle.Vehicle.Id)
-			responseString += fmt.Sprintf("vehicle_label: %s\n", *entity.Vehicle.Vehicle.Label)
-			responseString += fmt.Sprintf("latitude: %f\n", *entity.Vehicle.Position.Latitude)
-			responseString += fmt.Sprintf("longitude: %f\n", *entity.Vehicle.Position.Longitude)
-			responseString += fmt.Sprintf("bearing: %f\n", *entity.Vehicle.Position.Bearing)
-			if foundStop {
-				responseString += fmt.Sprintf("Stop: %v\n", stop)
-			} else {
-				responseString += fmt.Sprintf("Stop not found for Stop ID: %s\n", *entity.Vehicle.StopId)
-			}
-			responseString += fmt.Sprintf("current_status: %s\n", *entity.Vehicle.CurrentStatus)
-			responseString += fmt.Sprintf("timestamp: %d\n", *entity.Vehicle.Timestamp)
-			responseString += fmt.Sprintf("occupancy_status: %s\n", *entity.Vehicle.OccupancyStatus)
-			fmt.Fprintf(w, "%s", responseString)
-		}
+	snapshot, err := source.tripUpdates.Latest(r.Context())
+	if err != nil {
+		writeJSONStatus(w, http.StatusInternalServerError, map[string]string{"error": "Error fetching GTFS-RT: " + err.Error()})
+		return
 	}
+	setRealtimeHeaders(w, snapshot)
 
-	// Response: id: "1743462390_3176E117547F580BE063DC4D1FAC4CA4" vehicle: {trip: {trip_id: "115198391" route_id: "520" direction_id: 0 schedule_relationship:SCHEDULED
-	// } vehicle: {id: "3176E117547F580BE063DC4D1FAC4CA4" label: "6548"
-	// } position: {latitude: 39.9867 longitude: -104.805916 bearing: 92
-	// } stop_id: "20033" current_status:IN_TRANSIT_TO timestamp: 1743462366 occupancy_status:EMPTY
-	// }
+	data := source.Data()
+	response := map[string]interface{}{
+		"feed_id":      data.FeedID,
+		"header":       transportation.ConvertHeader(snapshot.Message),
+		"realtime":     realtimeStatus(snapshot, data.Location),
+		"trip_updates": transportation.ConvertTripUpdates(snapshot.Message),
+	}
+	writeJSON(w, response)
 }
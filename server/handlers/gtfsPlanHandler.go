@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"probable-system/main.go/processing"
+	"probable-system/main.go/server/services/transportation"
+)
+
+const (
+	defaultItineraries = 3
+	maxItineraries     = 5
+	// accessRadius is how far from a coordinate origin or destination
+	// stops are looked for.
+	accessRadius = 800.0
+	// maxAccessStops bounds how many of those stops the planner starts or
+	// ends at.
+	maxAccessStops = 20
+	// maxDirectWalk is the longest walk offered instead of riding.
+	maxDirectWalk = 2000.0
+)
+
+// planPlace is the origin or destination of a journey: a stop or station,
+// or a coordinate.
+type planPlace struct {
+	StopID string  `json:"stop_id,omitempty"`
+	Name   string  `json:"name,omitempty"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+}
+
+// resolvePlace reads a "lat,lon" coordinate or a stop ID, and returns the
+// stops a journey can start or end at there: the stops within accessRadius
+// of a coordinate, or a stop with its platforms when it is a station.
+func (d *StaticData) resolvePlace(value string) (planPlace, []processing.Access, error) {
+	if latText, lonText, found := strings.Cut(value, ","); found {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+		if latErr == nil && lonErr == nil {
+			var accesses []processing.Access
+			for _, stop := range d.StopIndex.Nearby(lat, lon, accessRadius, maxAccessStops) {
+				accesses = append(accesses, processing.Access{
+					StopID:   stop.StopID,
+					Walk:     processing.WalkDuration(stop.Distance),
+					Distance: stop.Distance,
+				})
+			}
+			return planPlace{Lat: lat, Lon: lon}, accesses, nil
+		}
+	}
+
+	if feedID, localID, found := splitGlobalID(value); found {
+		if feedID != d.FeedID {
+			return planPlace{}, nil, errors.New("journeys between feeds are not supported")
+		}
+		value = localID
+	}
+	stop, found := d.findStopById(value)
+	if !found {
+		return planPlace{}, nil, fmt.Errorf("stop %s not found", value)
+	}
+	accesses := []processing.Access{{StopID: stop.StopID}}
+	for _, childId := range d.StopChildren[stop.StopID] {
+		accesses = append(accesses, processing.Access{StopID: childId})
+	}
+	place := planPlace{StopID: stop.StopID, Name: stop.StopName, Lat: stop.StopLat, Lon: stop.StopLon}
+	return place, accesses, nil
+}
+
+// realtimeAdjust returns a PlanRequest.Adjust that moves scheduled times to
+// their predictions from updates. Updates without a start date are taken
+// to be for the run on currentDate.
+func (d *StaticData) realtimeAdjust(updates transportation.TripUpdates, currentDate time.Time) func(string, time.Time, int, time.Time) (time.Time, bool) {
+	current := currentDate.Format(processing.DateLayout)
+	return func(tripId string, serviceDate time.Time, position int, scheduled time.Time) (time.Time, bool) {
+		update, found := updates.Find(tripId, serviceDate.Format(processing.DateLayout), current)
+		if !found {
+			return scheduled, true
+		}
+		prediction := transportation.Predict(update, d.StopTimes[tripId], position, serviceDate, d.Location)
+		switch {
+		case prediction.Canceled, prediction.Skipped:
+			return scheduled, false
+		case prediction.HasEstimate():
+			return prediction.Departure, true
+		default:
+			return scheduled, true
+		}
+	}
+}
+
+func HandlePlan(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, _, ok := requestFeed(w, r, "")
+	if !ok {
+		return
+	}
+	data := feed.Data()
+
+	query := r.URL.Query()
+	from, origins, err := data.resolvePlace(query.Get("from"))
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	to, destinations, err := data.resolvePlace(query.Get("to"))
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Invalid to: " + err.Error()})
+		return
+	}
+	departAt := time.Now().In(data.Location)
+	if value := query.Get("depart_at"); value != "" {
+		departAt, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Invalid depart_at, expected an RFC 3339 time"})
+			return
+		}
+		departAt = departAt.In(data.Location)
+	}
+	count := defaultItineraries
+	if value := query.Get("count"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count <= 0 || count > maxItineraries {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Invalid count, expected 1 to 5"})
+			return
+		}
+	}
+
+	req := processing.PlanRequest{
+		Origins:      origins,
+		Destinations: destinations,
+		DepartAt:     departAt,
+		Itineraries:  count,
+		Calendar:     data.ServiceCalendar,
+		Location:     data.Location,
+	}
+	realtime := map[string]interface{}{
+		"requested": query.Get("realtime") == "true",
+		"applied":   false,
+	}
+	if query.Get("realtime") == "true" && feed.tripUpdates != nil {
+		snapshot, err := feed.tripUpdates.Latest(r.Context())
+		if err != nil {
+			fmt.Println("Error fetching GTFS-RT trip updates:", err)
+			realtime["error"] = err.Error()
+		} else {
+			serviceDate := time.Date(departAt.Year(), departAt.Month(), departAt.Day(), 0, 0, 0, 0, data.Location)
+			req.Adjust = data.realtimeAdjust(transportation.IndexTripUpdates(snapshot.Message), serviceDate)
+			for key, value := range realtimeStatus(snapshot, data.Location) {
+				realtime[key] = value
+			}
+			realtime["applied"] = true
+		}
+	}
+
+	type planStop struct {
+		StopID    string    `json:"stop_id"`
+		StopName  string    `json:"stop_name"`
+		Arrival   time.Time `json:"arrival"`
+		Departure time.Time `json:"departure"`
+	}
+	type planLeg struct {
+		processing.Leg
+		FromStopName   string     `json:"from_stop_name,omitempty"`
+		ToStopName     string     `json:"to_stop_name,omitempty"`
+		RouteID        string     `json:"route_id,omitempty"`
+		RouteShortName string     `json:"route_short_name,omitempty"`
+		RouteColor     string     `json:"route_color,omitempty"`
+		Headsign       string     `json:"headsign,omitempty"`
+		Stops          []planStop `json:"stops,omitempty"`
+	}
+	type planItinerary struct {
+		processing.Itinerary
+		Legs []planLeg `json:"legs"`
+	}
+
+	itineraries := []planItinerary{}
+	for _, itinerary := range data.Timetable.Plan(req) {
+		legs := make([]planLeg, 0, len(itinerary.Legs))
+		for _, leg := range itinerary.Legs {
+			next := planLeg{Leg: leg}
+			if stop, found := data.findStopById(leg.FromStopID); found {
+				next.FromStopName = stop.StopName
+			}
+			if stop, found := data.findStopById(leg.ToStopID); found {
+				next.ToStopName = stop.StopName
+			}
+			if leg.Mode == processing.LegTransit {
+				trip, _ := data.findTripById(leg.TripID)
+				route, _ := data.findRouteByID(trip.RouteID)
+				next.RouteID = route.RouteID
+				next.RouteShortName = route.RouteShortName
+				next.RouteColor = route.RouteColor
+				next.Headsign = trip.TripHeadsign
+				serviceDate, _ := processing.ParseDate(leg.ServiceDate, data.Location)
+				stopTimes, _ := data.findStopTimesByTripId(leg.TripID)
+				for _, stopTime := range stopTimes[leg.FromPosition : leg.ToPosition+1] {
+					stop, _ := data.findStopById(stopTime.StopID)
+					next.Stops = append(next.Stops, planStop{
+						StopID:    stopTime.StopID,
+						StopName:  stop.StopName,
+						Arrival:   stopTime.ArrivalTime.On(serviceDate, data.Location),
+						Departure: stopTime.DepartureTime.On(serviceDate, data.Location),
+					})
+				}
+			}
+			legs = append(legs, next)
+		}
+		itineraries = append(itineraries, planItinerary{Itinerary: itinerary, Legs: legs})
+	}
+
+	// Close enough to walk, walking is offered as an itinerary of its own.
+	if distance := processing.Distance(from.Lat, from.Lon, to.Lat, to.Lon); distance <= maxDirectWalk {
+		walk := processing.WalkDuration(distance)
+		leg := processing.Leg{
+			Mode:      processing.LegWalk,
+			Departure: departAt,
+			Arrival:   departAt.Add(time.Duration(walk) * time.Second),
+			Duration:  int(walk),
+			Distance:  distance,
+		}
+		itineraries = append(itineraries, planItinerary{
+			Itinerary: processing.Itinerary{
+				Departure: leg.Departure,
+				Arrival:   leg.Arrival,
+				Duration:  leg.Duration,
+				Transfers: 0,
+			},
+			Legs: []planLeg{{Leg: leg}},
+		})
+		sort.SliceStable(itineraries, func(i, j int) bool {
+			return itineraries[i].Arrival.Before(itineraries[j].Arrival)
+		})
+		if len(itineraries) > count {
+			itineraries = itineraries[:count]
+		}
+	}
+
+	response := map[string]interface{}{
+		"feed_id":     data.FeedID,
+		"from":        from,
+		"to":          to,
+		"depart_at":   departAt,
+		"realtime":    realtime,
+		"itineraries": itineraries,
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"probable-system/main.go/processing"
+)
+
+// maxFeedUpload bounds the size of a zip posted to the reload endpoint.
+const maxFeedUpload = 512 << 20
+
+// ReloadFeed loads the registered feed feedID from its static source and
+// swaps it in. The current data is kept if the new feed fails to load.
+func ReloadFeed(feedID string) (*StaticData, error) {
+	feed, found := feeds[feedID]
+	if !found {
+		return nil, fmt.Errorf("feed %q is not registered", feedID)
+	}
+	feed.reloadMu.Lock()
+	defer feed.reloadMu.Unlock()
+
+	fmt.Printf("Reloading GTFS feed %s from %s\n", feedID, feed.config.Static)
+	var loaded *processing.Feed
+	var err error
+	if feed.config.Snapshot != "" {
+		loaded, err = processing.LoadFeedCached(feed.config.Static, feed.config.Snapshot)
+	} else {
+		loaded, err = processing.LoadFeed(feed.config.Static)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return feed.swap(loaded)
+}
+
+// ReloadFeedZip loads the registered feed feedID from the bytes of a zip
+// archive and swaps it in. The archive replaces the data in memory only; the
+// configured source is left as it is and is used again by the next
+// ReloadFeed.
+func ReloadFeedZip(feedID string, archive []byte) (*StaticData, error) {
+	feed, found := feeds[feedID]
+	if !found {
+		return nil, fmt.Errorf("feed %q is not registered", feedID)
+	}
+	feed.reloadMu.Lock()
+	defer feed.reloadMu.Unlock()
+
+	loaded, err := processing.LoadFeedZip(archive)
+	if err != nil {
+		return nil, err
+	}
+	return feed.swap(loaded)
+}
+
+// swap builds the lookup maps for loaded and makes them current, unless the
+// feed lacks a required file that the current feed has. Requests already
+// holding the old data finish with it.
+func (f *feedState) swap(loaded *processing.Feed) (*StaticData, error) {
+	current := f.Data()
+	missing := loaded.MissingRequired()
+	for _, name := range missing {
+		if !slices.Contains(current.Feed.MissingRequired(), name) {
+			return nil, fmt.Errorf("new feed is missing required files: %v", missing)
+		}
+	}
+
+	data := NewStaticData(f.config.ID, loaded)
+	f.data.Store(data)
+	fmt.Printf("GTFS feed %s reloaded with %d routes, %d trips and %d stops\n", f.config.ID, len(data.Routes), len(data.Trips), len(data.Stops))
+	return data, nil
+}
+
+// WatchFeeds polls the static source of every registered feed each interval
+// and reloads a feed when a file in its source is added, removed or
+// replaced.
+func WatchFeeds(interval time.Duration) {
+	for _, feedID := range feedOrder {
+		go watchFeed(feedID, interval)
+	}
+}
+
+func watchFeed(feedID string, interval time.Duration) {
+	path := feeds[feedID].config.Static
+	last, err := processing.FeedFingerprint(path)
+	if err != nil {
+		fmt.Printf("Error watching GTFS feed %s: %v\n", feedID, err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		fingerprint, err := processing.FeedFingerprint(path)
+		if err != nil {
+			fmt.Printf("Error watching GTFS feed %s: %v\n", feedID, err)
+			continue
+		}
+		if fingerprint == last {
+			continue
+		}
+		// Wait for the files to stop changing, so a feed still being
+		// copied into place is not loaded half written.
+		time.Sleep(interval / 2)
+		if settled, err := processing.FeedFingerprint(path); err != nil || settled != fingerprint {
+			continue
+		}
+		// A failed load is retried on the next tick, as the files may only
+		// have been unreadable for a moment.
+		if _, err := ReloadFeed(feedID); err != nil {
+			fmt.Printf("Error reloading GTFS feed %s: %v\n", feedID, err)
+			continue
+		}
+		last = fingerprint
+	}
+}
+
+// writeUploadError answers a reload whose feed archive could not be read,
+// with 413 when it is larger than maxFeedUpload.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONStatus(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("Feed archive is larger than %d bytes", maxFeedUpload)})
+		return
+	}
+	if errors.Is(err, http.ErrMissingFile) {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "The upload has no feed form file"})
+		return
+	}
+	writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Failed to read feed archive: " + err.Error()})
+}
+
+// HandleReloadFeed reloads the static data of the requested feed. A zip
+// archive posted as the "feed" form file or as an application/zip body is
+// loaded in place of the configured source.
+func HandleReloadFeed(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, _, ok := requestFeed(w, r, "")
+	if !ok {
+		return
+	}
+
+	// A request that carries an upload must load it: if the upload cannot
+	// be read, reloading the configured source instead would report
+	// success while the old feed is still served.
+	r.Body = http.MaxBytesReader(w, r.Body, maxFeedUpload)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var archive []byte
+	switch {
+	case mediaType == "application/zip":
+		var err error
+		archive, err = io.ReadAll(r.Body)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	case mediaType == "multipart/form-data":
+		file, _, err := r.FormFile("feed")
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		defer file.Close()
+		archive, err = io.ReadAll(file)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	case r.ContentLength != 0:
+		writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "Post the feed as an application/zip body or as the feed form file"})
+		return
+	}
+
+	var data *StaticData
+	var err error
+	if archive != nil {
+		data, err = ReloadFeedZip(feed.config.ID, archive)
+	} else {
+		data, err = ReloadFeed(feed.config.ID)
+	}
+	if err != nil {
+		response := map[string]interface{}{
+			"feed_id":  feed.config.ID,
+			"reloaded": false,
+			"error":    err.Error(),
+		}
+		var parseErrs *processing.ParseErrors
+		if errors.As(err, &parseErrs) {
+			response["parse_errors"] = parseErrs.Errors
+		}
+		fmt.Printf("Error reloading GTFS feed %s: %v\n", feed.config.ID, err)
+		writeJSONStatus(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	response := map[string]interface{}{
+		"feed_id":   data.FeedID,
+		"reloaded":  true,
+		"loaded_at": data.LoadedAt,
+		"feed_info": data.Feed.FeedInfo,
+		"counts": map[string]int{
+			"routes":      len(data.Routes),
+			"trips":       len(data.Trips),
+			"stops":       len(data.Stops),
+			"stop_times":  len(data.Feed.StopTimes),
+			"frequencies": len(data.Feed.Frequencies),
+			"shapes":      len(data.Shapes),
+		},
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"probable-system/main.go/processing"
+)
+
+// GTFS colors for routes that leave route_color or route_text_color empty.
+const (
+	defaultRouteColor     = "FFFFFF"
+	defaultRouteTextColor = "000000"
+)
+
+// patternStop is a stop of a route pattern.
+type patternStop struct {
+	StopID   string  `json:"stop_id"`
+	StopName string  `json:"stop_name"`
+	StopLat  float64 `json:"stop_lat"`
+	StopLon  float64 `json:"stop_lon"`
+}
+
+// routePattern is a distinct sequence of stops the trips of a route make in
+// one direction.
+type routePattern struct {
+	PatternID       string        `json:"pattern_id"`
+	DirectionID     int           `json:"direction_id"`
+	Headsigns       []string      `json:"headsigns"`
+	Trips           int           `json:"trips"`
+	ShapeID         string        `json:"shape_id"`
+	EncodedPolyline string        `json:"encoded_polyline"`
+	Stops           []patternStop `json:"stops"`
+	stopIds         []string
+	shapeTrips      map[string]int
+}
+
+// routePatterns groups the trips of a route by direction and sequence of
+// stops, most used first within each direction. A pattern's shape is the
+// one most of its trips follow.
+func (d *StaticData) routePatterns(routeId string) []*routePattern {
+	var patterns []*routePattern
+	byKey := make(map[string]*routePattern)
+	for _, trip := range d.RouteTrips[routeId] {
+		stopTimes := d.StopTimes[trip.TripID]
+		if len(stopTimes) == 0 {
+			continue
+		}
+		stopIds := make([]string, 0, len(stopTimes))
+		for _, stopTime := range stopTimes {
+			stopIds = append(stopIds, stopTime.StopID)
+		}
+		key := strconv.Itoa(trip.DirectionID) + "\x00" + strings.Join(stopIds, "\x00")
+		pattern, seen := byKey[key]
+		if !seen {
+			pattern = &routePattern{DirectionID: trip.DirectionID, Headsigns: []string{}, stopIds: stopIds, shapeTrips: make(map[string]int)}
+			byKey[key] = pattern
+			patterns = append(patterns, pattern)
+		}
+		pattern.Trips++
+		if trip.ShapeID != "" {
+			pattern.shapeTrips[trip.ShapeID]++
+		}
+		if trip.TripHeadsign != "" && !slices.Contains(pattern.Headsigns, trip.TripHeadsign) {
+			pattern.Headsigns = append(pattern.Headsigns, trip.TripHeadsign)
+		}
+	}
+
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if patterns[i].DirectionID != patterns[j].DirectionID {
+			return patterns[i].DirectionID < patterns[j].DirectionID
+		}
+		return patterns[i].Trips > patterns[j].Trips
+	})
+
+	counts := make(map[int]int)
+	for _, pattern := range patterns {
+		pattern.PatternID = fmt.Sprintf("%s:%d:%d", routeId, pattern.DirectionID, counts[pattern.DirectionID])
+		counts[pattern.DirectionID]++
+
+		for shapeId, trips := range pattern.shapeTrips {
+			if trips > pattern.shapeTrips[pattern.ShapeID] || (trips == pattern.shapeTrips[pattern.ShapeID] && shapeId < pattern.ShapeID) {
+				pattern.ShapeID = shapeId
+			}
+		}
+		if points, found := d.findShapeById(pattern.ShapeID); found {
+			pattern.EncodedPolyline = processing.EncodePolyline(points)
+		}
+
+		pattern.Stops = make([]patternStop, 0, len(pattern.stopIds))
+		for _, stopId := range pattern.stopIds {
+			stop, _ := d.findStopById(stopId)
+			pattern.Stops = append(pattern.Stops, patternStop{
+				StopID:   stopId,
+				StopName: stop.StopName,
+				StopLat:  stop.StopLat,
+				StopLon:  stop.StopLon,
+			})
+		}
+	}
+	return patterns
+}
+
+// routeColors returns the colors of a route, with the GTFS defaults for
+// those it leaves empty.
+func routeColors(route processing.Route) (string, string) {
+	color, textColor := route.RouteColor, route.RouteTextColor
+	if color == "" {
+		color = defaultRouteColor
+	}
+	if textColor == "" {
+		textColor = defaultRouteTextColor
+	}
+	return color, textColor
+}
+
+// routeNameLess orders route short names by their leading number, so 2
+// comes before 10 and 15 before 15L, and otherwise alphabetically.
+func routeNameLess(a, b string) bool {
+	numberA, restA := splitRouteNumber(a)
+	numberB, restB := splitRouteNumber(b)
+	if numberA != numberB {
+		return numberA < numberB
+	}
+	return restA < restB
+}
+
+// splitRouteNumber splits the leading digits off a route short name. Names
+// without a leading number sort after the numbered ones.
+func splitRouteNumber(name string) (int, string) {
+	digits := 0
+	for digits < len(name) && name[digits] >= '0' && name[digits] <= '9' {
+		digits++
+	}
+	number, err := strconv.Atoi(name[:digits])
+	if err != nil {
+		return math.MaxInt, name
+	}
+	return number, name[digits:]
+}
+
+func HandleRoutes(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	routeType := -1
+	if value := r.URL.Query().Get("route_type"); value != "" {
+		var err error
+		routeType, err = strconv.Atoi(value)
+		if err != nil || routeType < 0 {
+			http.Error(w, `{"error": "Invalid route_type"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	type routeListing struct {
+		routeSummary
+		AgencyID       string `json:"agency_id"`
+		RouteSortOrder int    `json:"route_sort_order"`
+		Trips          int    `json:"trips"`
+	}
+
+	routes := []routeListing{}
+	for _, route := range data.Routes {
+		if routeType >= 0 && route.RouteType != routeType {
+			continue
+		}
+		color, textColor := routeColors(route)
+		routes = append(routes, routeListing{
+			routeSummary: routeSummary{
+				RouteID:        route.RouteID,
+				RouteShortName: route.RouteShortName,
+				RouteLongName:  route.RouteLongName,
+				RouteType:      route.RouteType,
+				RouteColor:     color,
+				RouteTextColor: textColor,
+			},
+			AgencyID:       route.AgencyID,
+			RouteSortOrder: route.RouteSortOrder,
+			Trips:          len(data.RouteTrips[route.RouteID]),
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].RouteSortOrder != routes[j].RouteSortOrder {
+			return routes[i].RouteSortOrder < routes[j].RouteSortOrder
+		}
+		if routes[i].RouteShortName != routes[j].RouteShortName {
+			return routeNameLess(routes[i].RouteShortName, routes[j].RouteShortName)
+		}
+		return routes[i].RouteID < routes[j].RouteID
+	})
+
+	response := map[string]interface{}{
+		"feed_id": data.FeedID,
+		"count":   len(routes),
+		"routes":  routes,
+	}
+	writeJSON(w, response)
+}
+
+func HandleRoute(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, id, ok := requestData(w, r, id)
+	if !ok {
+		return
+	}
+
+	route, found := data.findRouteByID(id)
+	if !found {
+		http.Error(w, `{"error": "Route not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// The shape of a direction is that of its most used pattern.
+	type routeDirection struct {
+		DirectionID int             `json:"direction_id"`
+		Headsigns   []string        `json:"headsigns"`
+		Trips       int             `json:"trips"`
+		ShapeID     string          `json:"shape_id"`
+		Patterns    []*routePattern `json:"patterns"`
+	}
+
+	directions := []*routeDirection{}
+	for _, pattern := range data.routePatterns(route.RouteID) {
+		if len(directions) == 0 || directions[len(directions)-1].DirectionID != pattern.DirectionID {
+			directions = append(directions, &routeDirection{DirectionID: pattern.DirectionID, Headsigns: []string{}, ShapeID: pattern.ShapeID})
+		}
+		direction := directions[len(directions)-1]
+		direction.Trips += pattern.Trips
+		direction.Patterns = append(direction.Patterns, pattern)
+		for _, headsign := range pattern.Headsigns {
+			if !slices.Contains(direction.Headsigns, headsign) {
+				direction.Headsigns = append(direction.Headsigns, headsign)
+			}
+		}
+	}
+
+	color, textColor := routeColors(route)
+	response := map[string]interface{}{
+		"feed_id":          data.FeedID,
+		"route":            route,
+		"route_color":      color,
+		"route_text_color": textColor,
+		"directions":       directions,
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"probable-system/main.go/processing"
+	"probable-system/main.go/processing/validator"
+)
+
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	writeJSONStatus(w, http.StatusOK, response)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, response interface{}) {
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to encode response"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
+
+func HandleServiceDate(w http.ResponseWriter, r *http.Request, date string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	day := processing.Today(data.Location)
+	if date != "today" {
+		var err error
+		day, err = processing.ParseDate(date, data.Location)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid date, expected YYYYMMDD or today"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	type serviceResponse struct {
+		processing.ServiceStatus
+		Trips int `json:"trips"`
+	}
+
+	var active []serviceResponse
+	var removed []serviceResponse
+	totalTrips := 0
+	for _, status := range data.ServiceCalendar.Statuses(day) {
+		service := serviceResponse{ServiceStatus: status, Trips: data.ServiceTripCounts[status.ServiceID]}
+		if status.Active {
+			active = append(active, service)
+			totalTrips += service.Trips
+		} else if status.Exception != "" {
+			removed = append(removed, service)
+		}
+	}
+
+	response := map[string]interface{}{
+		"feed_id":          data.FeedID,
+		"date":             day.Format(processing.DateLayout),
+		"weekday":          day.Weekday().String(),
+		"active_services":  active,
+		"removed_services": removed,
+		"total_trips":      totalTrips,
+	}
+	writeJSON(w, response)
+}
+
+func HandleAgency(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	response := map[string]interface{}{
+		"feed_id":  data.FeedID,
+		"agencies": data.Feed.Agencies,
+		"timezone": data.Location.String(),
+	}
+	writeJSON(w, response)
+}
+
+func HandleFeedInfo(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	today := processing.Today(data.Location)
+	warnings := data.Feed.ValidityWarnings(today)
+	response := map[string]interface{}{
+		"feed_id":   data.FeedID,
+		"feed_info": data.Feed.FeedInfo,
+		"files":     data.Feed.Files,
+		"today":     today.Format(processing.DateLayout),
+		"timezone":  data.Location.String(),
+		"valid":     len(warnings) == 0,
+		"warnings":  warnings,
+	}
+	writeJSON(w, response)
+}
+
+func HandleHealth(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := "ok"
+	warnings := []string{}
+	for _, feedID := range feedOrder {
+		data := feeds[feedID].Data()
+		feedWarnings := data.Feed.ValidityWarnings(processing.Today(data.Location))
+		if missing := data.Feed.MissingRequired(); len(missing) > 0 {
+			feedWarnings = append(feedWarnings, fmt.Sprintf("feed is missing required files: %v", missing))
+		}
+		for _, warning := range feedWarnings {
+			warnings = append(warnings, feedID+": "+warning)
+		}
+	}
+	if len(warnings) > 0 {
+		status = "degraded"
+	}
+
+	response := map[string]interface{}{
+		"status":   status,
+		"warnings": warnings,
+	}
+	writeJSON(w, response)
+}
+
+func HandleValidateFeed(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	report := validator.Validate(data.Feed, processing.Today(data.Location))
+	response := map[string]interface{}{
+		"feed_id": data.FeedID,
+		"valid":   report.Valid(),
+		"report":  report,
+	}
+	writeJSON(w, response)
+}
+
+func HandleShape(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, id, ok := requestData(w, r, id)
+	if !ok {
+		return
+	}
+
+	points, found := data.findShapeById(id)
+	if !found {
+		http.Error(w, `{"error": "Shape not found"}`, http.StatusNotFound)
+		return
+	}
+
+	feature := processing.ShapeLineString(id, points)
+	feature.Properties["feed_id"] = data.FeedID
+	switch r.URL.Query().Get("format") {
+	case "geojson":
+		writeJSON(w, feature)
+	case "polyline":
+		writeJSON(w, map[string]interface{}{
+			"feed_id":          data.FeedID,
+			"shape_id":         id,
+			"encoded_polyline": processing.EncodePolyline(points),
+		})
+	default:
+		writeJSON(w, map[string]interface{}{
+			"feed_id":          data.FeedID,
+			"shape_id":         id,
+			"points":           len(points),
+			"geojson":          feature,
+			"encoded_polyline": processing.EncodePolyline(points),
+		})
+	}
+}
+
+func HandleRouteShapes(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, id, ok := requestData(w, r, id)
+	if !ok {
+		return
+	}
+
+	route, found := data.findRouteByID(id)
+	if !found {
+		http.Error(w, `{"error": "Route not found"}`, http.StatusNotFound)
+		return
+	}
+
+	type shapeVariant struct {
+		ShapeID         string   `json:"shape_id"`
+		DirectionID     int      `json:"direction_id"`
+		Headsigns       []string `json:"headsigns"`
+		Trips           int      `json:"trips"`
+		EncodedPolyline string   `json:"encoded_polyline"`
+	}
+
+	var variants []*shapeVariant
+	byShape := make(map[string]*shapeVariant)
+	for _, trip := range data.RouteTrips[route.RouteID] {
+		if trip.ShapeID == "" {
+			continue
+		}
+		variant, seen := byShape[trip.ShapeID]
+		if !seen {
+			variant = &shapeVariant{ShapeID: trip.ShapeID, DirectionID: trip.DirectionID}
+			byShape[trip.ShapeID] = variant
+			variants = append(variants, variant)
+		}
+		variant.Trips++
+		if trip.TripHeadsign != "" && !slices.Contains(variant.Headsigns, trip.TripHeadsign) {
+			variant.Headsigns = append(variant.Headsigns, trip.TripHeadsign)
+		}
+	}
+
+	collection := processing.GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []processing.GeoJSONFeature{}}
+	for _, variant := range variants {
+		points, found := data.findShapeById(variant.ShapeID)
+		if !found {
+			continue
+		}
+		variant.EncodedPolyline = processing.EncodePolyline(points)
+		feature := processing.ShapeLineString(variant.ShapeID, points)
+		feature.Properties["feed_id"] = data.FeedID
+		feature.Properties["route_id"] = route.RouteID
+		feature.Properties["direction_id"] = variant.DirectionID
+		feature.Properties["headsigns"] = variant.Headsigns
+		feature.Properties["trips"] = variant.Trips
+		feature.Properties["route_color"] = route.RouteColor
+		collection.Features = append(collection.Features, feature)
+	}
+
+	response := map[string]interface{}{
+		"feed_id": data.FeedID,
+		"route":   route,
+		"shapes":  variants,
+		"geojson": collection,
+	}
+	writeJSON(w, response)
+}
+
+func HandleTrip(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, id, ok := requestData(w, r, id)
+	if !ok {
+		return
+	}
+
+	trip, found := data.findTripById(id)
+	if !found {
+		http.Error(w, `{"error": "Trip not found"}`, http.StatusNotFound)
+		return
+	}
+	route, _ := data.findRouteByID(trip.RouteID)
+	stopTimes, _ := data.findStopTimesByTripId(trip.TripID)
+
+	// With a service date the schedule times are also given as instants in
+	// the agency timezone.
+	var serviceDate time.Time
+	if date := r.URL.Query().Get("date"); date != "" {
+		var err error
+		serviceDate, err = processing.ParseDate(date, data.Location)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid date, expected YYYYMMDD"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	type tripStop struct {
+		processing.StopTime
+		StopName  string     `json:"stop_name"`
+		StopLat   float64    `json:"stop_lat"`
+		StopLon   float64    `json:"stop_lon"`
+		Arrival   *time.Time `json:"arrival,omitempty"`
+		Departure *time.Time `json:"departure,omitempty"`
+	}
+
+	stops := make([]tripStop, 0, len(stopTimes))
+	for _, stopTime := range stopTimes {
+		stop, _ := data.findStopById(stopTime.StopID)
+		tripStop := tripStop{
+			StopTime: stopTime,
+			StopName: stop.StopName,
+			StopLat:  stop.StopLat,
+			StopLon:  stop.StopLon,
+		}
+		if !serviceDate.IsZero() {
+			arrival := stopTime.ArrivalTime.On(serviceDate, data.Location)
+			departure := stopTime.DepartureTime.On(serviceDate, data.Location)
+			tripStop.Arrival, tripStop.Departure = &arrival, &departure
+		}
+		stops = append(stops, tripStop)
+	}
+
+	response := map[string]interface{}{
+		"feed_id":  data.FeedID,
+		"trip":     trip,
+		"route":    route,
+		"headsign": trip.TripHeadsign,
+		"stops":    stops,
+	}
+	if !serviceDate.IsZero() {
+		response["service_date"] = serviceDate.Format(processing.DateLayout)
+		response["runs_on_date"] = data.ServiceCalendar.IsActive(trip.ServiceID, serviceDate)
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+
+	"probable-system/main.go/processing"
+)
+
+// stationLocation is a platform, entrance, node or boarding area of a
+// station.
+type stationLocation struct {
+	StopID             string  `json:"stop_id"`
+	StopName           string  `json:"stop_name"`
+	StopCode           string  `json:"stop_code,omitempty"`
+	PlatformCode       string  `json:"platform_code,omitempty"`
+	LevelID            string  `json:"level_id,omitempty"`
+	StopLat            float64 `json:"stop_lat"`
+	StopLon            float64 `json:"stop_lon"`
+	WheelchairBoarding int     `json:"wheelchair_boarding"`
+	// StepFreeAccess tells whether the location can be reached from an
+	// entrance without steps or, for an entrance, whether a platform can be
+	// reached from it. It is left out when the feed has no pathways for the
+	// station.
+	StepFreeAccess *bool             `json:"step_free_access,omitempty"`
+	Routes         []routeSummary    `json:"routes,omitempty"`
+	BoardingAreas  []stationLocation `json:"boarding_areas,omitempty"`
+}
+
+// stationLocations describes the stops stopIds of a station. A location
+// that leaves wheelchair_boarding empty takes the value of its parent.
+func (d *StaticData) stationLocations(stopIds []string, stepFree map[string]bool) []stationLocation {
+	locations := []stationLocation{}
+	for _, stopId := range stopIds {
+		stop, found := d.findStopById(stopId)
+		if !found {
+			continue
+		}
+		location := stationLocation{
+			StopID:             stop.StopID,
+			StopName:           stop.StopName,
+			StopCode:           stop.StopCode,
+			PlatformCode:       stop.PlatformCode,
+			LevelID:            stop.LevelID,
+			StopLat:            stop.StopLat,
+			StopLon:            stop.StopLon,
+			WheelchairBoarding: stop.WheelchairBoarding,
+		}
+		if location.WheelchairBoarding == 0 && stop.ParentStation != "" {
+			parent, _ := d.findStopById(stop.ParentStation)
+			location.WheelchairBoarding = parent.WheelchairBoarding
+		}
+		if stepFree != nil {
+			reachable := stepFree[stop.StopID]
+			location.StepFreeAccess = &reachable
+		}
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+func HandleStation(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, id, ok := requestData(w, r, id)
+	if !ok {
+		return
+	}
+
+	station, found := data.Stations.Station(id)
+	if !found {
+		http.Error(w, `{"error": "Station not found"}`, http.StatusNotFound)
+		return
+	}
+	stop, _ := data.findStopById(station.StationID)
+	stepFree := station.StepFreeFrom(station.Entrances...)
+
+	platforms := data.stationLocations(station.Platforms, stepFree)
+	sort.SliceStable(platforms, func(i, j int) bool {
+		return platforms[i].StopName < platforms[j].StopName
+	})
+	for i := range platforms {
+		platforms[i].Routes = data.stopRoutes(platforms[i].StopID)
+		if areas := station.BoardingAreas[platforms[i].StopID]; len(areas) > 0 {
+			platforms[i].BoardingAreas = data.stationLocations(areas, stepFree)
+		}
+	}
+
+	type stationPathway struct {
+		processing.Pathway
+		Mode     string `json:"mode"`
+		StepFree bool   `json:"step_free"`
+	}
+	pathways := make([]stationPathway, 0, len(station.Pathways))
+	for _, pathway := range station.Pathways {
+		pathways = append(pathways, stationPathway{
+			Pathway:  pathway,
+			Mode:     processing.PathwayModeName(pathway.PathwayMode),
+			StepFree: pathway.StepFree(),
+		})
+	}
+
+	entrances := data.stationLocations(station.Entrances, stepFree)
+	for i := range entrances {
+		if reachable := station.StepFreeFrom(entrances[i].StopID); reachable != nil {
+			toPlatform := slices.ContainsFunc(station.Platforms, func(platformId string) bool { return reachable[platformId] })
+			entrances[i].StepFreeAccess = &toPlatform
+		}
+	}
+
+	levels := station.Levels
+	if levels == nil {
+		levels = []processing.Level{}
+	}
+	response := map[string]interface{}{
+		"feed_id":   data.FeedID,
+		"station":   stop,
+		"levels":    levels,
+		"platforms": platforms,
+		"entrances": entrances,
+		"nodes":     data.stationLocations(station.Nodes, stepFree),
+		"pathways":  pathways,
+	}
+	writeJSON(w, response)
+}
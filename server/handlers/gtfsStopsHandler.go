@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"probable-system/main.go/processing"
+)
+
+const (
+	defaultNearbyRadius = 500.0
+	maxNearbyRadius     = 5000.0
+	defaultNearbyLimit  = 20
+	maxNearbyLimit      = 100
+	defaultSearchLimit  = 10
+	maxSearchLimit      = 50
+)
+
+// routeSummary is the part of a route shown alongside a stop.
+type routeSummary struct {
+	RouteID        string `json:"route_id"`
+	RouteShortName string `json:"route_short_name"`
+	RouteLongName  string `json:"route_long_name"`
+	RouteType      int    `json:"route_type"`
+	RouteColor     string `json:"route_color"`
+	RouteTextColor string `json:"route_text_color"`
+}
+
+// stopRoutes returns the routes serving a stop.
+func (d *StaticData) stopRoutes(stopId string) []routeSummary {
+	routes := []routeSummary{}
+	for _, routeId := range d.StopRoutes[stopId] {
+		route, found := d.findRouteByID(routeId)
+		if !found {
+			continue
+		}
+		routes = append(routes, routeSummary{
+			RouteID:        route.RouteID,
+			RouteShortName: route.RouteShortName,
+			RouteLongName:  route.RouteLongName,
+			RouteType:      route.RouteType,
+			RouteColor:     route.RouteColor,
+			RouteTextColor: route.RouteTextColor,
+		})
+	}
+	return routes
+}
+
+func HandleNearbyStops(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		http.Error(w, `{"error": "Invalid lat, expected degrees between -90 and 90"}`, http.StatusBadRequest)
+		return
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		http.Error(w, `{"error": "Invalid lon, expected degrees between -180 and 180"}`, http.StatusBadRequest)
+		return
+	}
+	radius := defaultNearbyRadius
+	if value := query.Get("radius"); value != "" {
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			http.Error(w, `{"error": "Invalid radius, expected meters up to 5000"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultNearbyLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxNearbyLimit {
+			http.Error(w, `{"error": "Invalid limit, expected 1 to 100"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	type nearbyStop struct {
+		processing.NearbyStop
+		Routes []routeSummary `json:"routes"`
+	}
+
+	nearby := data.StopIndex.Nearby(lat, lon, radius, limit)
+	stops := make([]nearbyStop, 0, len(nearby))
+	for _, stop := range nearby {
+		stops = append(stops, nearbyStop{NearbyStop: stop, Routes: data.stopRoutes(stop.StopID)})
+	}
+
+	response := map[string]interface{}{
+		"feed_id": data.FeedID,
+		"lat":     lat,
+		"lon":     lon,
+		"radius":  radius,
+		"stops":   stops,
+	}
+	writeJSON(w, response)
+}
+
+func HandleStopSearch(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, _, ok := requestData(w, r, "")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if len(processing.SearchTokens(q)) == 0 {
+		http.Error(w, `{"error": "Missing search query q"}`, http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			http.Error(w, `{"error": "Invalid limit, expected 1 to 50"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	type stopResult struct {
+		processing.StopMatch
+		Routes []routeSummary `json:"routes"`
+	}
+
+	matches := data.StopSearch.Search(q, limit)
+	stops := make([]stopResult, 0, len(matches))
+	for _, match := range matches {
+		stops = append(stops, stopResult{StopMatch: match, Routes: data.stopRoutes(match.StopID)})
+	}
+
+	response := map[string]interface{}{
+		"feed_id": data.FeedID,
+		"query":   q,
+		"stops":   stops,
+	}
+	writeJSON(w, response)
+}
+
+func HandleStopTransfers(w http.ResponseWriter, r *http.Request, id string) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, id, ok := requestData(w, r, id)
+	if !ok {
+		return
+	}
+
+	stop, found := data.findStopById(id)
+	if !found {
+		http.Error(w, `{"error": "Stop not found"}`, http.StatusNotFound)
+		return
+	}
+
+	type stopTransfer struct {
+		processing.Transfer
+		ToStopName string `json:"to_stop_name"`
+	}
+
+	// A station's transfers include those of its platforms, and a
+	// platform's include those of its station.
+	transfers := data.Transfers.Applying(stop.StopID)
+	for _, childId := range data.StopChildren[stop.StopID] {
+		transfers = append(transfers[:len(transfers):len(transfers)], data.Transfers.From(childId)...)
+	}
+	results := make([]stopTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		toStop, _ := data.findStopById(transfer.ToStopID)
+		results = append(results, stopTransfer{Transfer: transfer, ToStopName: toStop.StopName})
+	}
+
+	response := map[string]interface{}{
+		"feed_id":   data.FeedID,
+		"stop":      stop,
+		"transfers": results,
+	}
+	writeJSON(w, response)
+}
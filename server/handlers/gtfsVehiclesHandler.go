@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+
+	"probable-system/main.go/processing"
+	"probable-system/main.go/server/services/db"
+	"probable-system/main.go/server/services/transportation"
+)
+
+// vehicle is a GTFS-RT vehicle position joined with the static trip, route
+// and stop it refers to. Each is nil when the feed leaves the ID out or
+// names one the static data does not have; the IDs that were given but not
+// found are listed in Unmatched.
+type vehicle struct {
+	db.VehiclePosition
+	ScheduledTrip *processing.Trip  `json:"scheduled_trip"`
+	Route         *routeSummary     `json:"route"`
+	Stop          *patternStop      `json:"stop"`
+	Unmatched     *vehicleUnmatched `json:"unmatched,omitempty"`
+}
+
+// vehicleUnmatched holds the IDs of a vehicle position that are not in the
+// static data.
+type vehicleUnmatched struct {
+	TripID  string `json:"trip_id,omitempty"`
+	RouteID string `json:"route_id,omitempty"`
+	StopID  string `json:"stop_id,omitempty"`
+}
+
+// vehicleTrip finds the trip of a trip descriptor, which for a trip
+// repeated by frequencies.txt is the run starting at the descriptor's
+// start_time.
+func (d *StaticData) vehicleTrip(descriptor db.TripDescriptor) (processing.Trip, bool) {
+	if trip, found := d.findTripById(descriptor.TripID); found {
+		return trip, true
+	}
+	if startTime, err := processing.ParseTime(descriptor.StartTime); err == nil && startTime.IsSet() {
+		return d.findTripById(processing.FrequencyRunID(descriptor.TripID, startTime))
+	}
+	return processing.Trip{}, false
+}
+
+// enrichVehicles joins vehicle positions with the static data. A position
+// without a route_id takes the route of its trip.
+func (d *StaticData) enrichVehicles(positions []db.VehiclePosition) []vehicle {
+	vehicles := make([]vehicle, 0, len(positions))
+	for _, position := range positions {
+		next := vehicle{VehiclePosition: position}
+		unmatched := vehicleUnmatched{}
+
+		routeId := position.Trip.RouteID
+		if tripId := position.Trip.TripID; tripId != "" {
+			if trip, found := d.vehicleTrip(position.Trip); found {
+				next.ScheduledTrip = &trip
+				if routeId == "" {
+					routeId = trip.RouteID
+				}
+			} else {
+				unmatched.TripID = tripId
+			}
+		}
+		if routeId != "" {
+			if route, found := d.findRouteByID(routeId); found {
+				color, textColor := routeColors(route)
+				next.Route = &routeSummary{
+					RouteID:        route.RouteID,
+					RouteShortName: route.RouteShortName,
+					RouteLongName:  route.RouteLongName,
+					RouteType:      route.RouteType,
+					RouteColor:     color,
+					RouteTextColor: textColor,
+				}
+			} else {
+				unmatched.RouteID = routeId
+			}
+		}
+		if stopId := position.StopID; stopId != "" {
+			if stop, found := d.findStopById(stopId); found {
+				next.Stop = &patternStop{
+					StopID:   stop.StopID,
+					StopName: stop.StopName,
+					StopLat:  stop.StopLat,
+					StopLon:  stop.StopLon,
+				}
+			} else {
+				unmatched.StopID = stopId
+			}
+		}
+
+		if unmatched != (vehicleUnmatched{}) {
+			next.Unmatched = &unmatched
+		}
+		vehicles = append(vehicles, next)
+	}
+	return vehicles
+}
+
+func HandleVehiclePosition(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source, _, ok := requestFeed(w, r, "")
+	if !ok {
+		return
+	}
+	if source.vehiclePositions == nil {
+		http.Error(w, `{"error": "Feed has no vehicle positions feed"}`, http.StatusNotFound)
+		return
+	}
+
+	snapshot, err := source.vehiclePositions.Latest(r.Context())
+	if err != nil {
+		writeJSONStatus(w, http.StatusInternalServerError, map[string]string{"error": "Error fetching GTFS-RT: " + err.Error()})
+		return
+	}
+	setRealtimeHeaders(w, snapshot)
+
+	data := source.Data()
+	vehicles := data.enrichVehicles(transportation.ConvertVehiclePositions(snapshot.Message))
+	unmatched := 0
+	for _, vehicle := range vehicles {
+		if vehicle.Unmatched != nil {
+			unmatched++
+		}
+	}
+
+	response := map[string]interface{}{
+		"feed_id":           data.FeedID,
+		"header":            transportation.ConvertHeader(snapshot.Message),
+		"realtime":          realtimeStatus(snapshot, data.Location),
+		"unmatched":         unmatched,
+		"vehicle_positions": vehicles,
+	}
+	writeJSON(w, response)
+}
@@ -133,4 +133,69 @@ func addGTFSRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/gtfs/vehicleposition", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		handlers.HandleVehiclePosition(w, r)
 	}))
+	mux.HandleFunc("/gtfs/service/{date}", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		date := r.PathValue("date")
+		handlers.HandleServiceDate(w, r, date)
+	}))
+	mux.HandleFunc("/gtfs/feeds", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleFeeds(w, r)
+	}))
+	mux.HandleFunc("/gtfs/agency", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleAgency(w, r)
+	}))
+	mux.HandleFunc("/gtfs/feed", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleFeedInfo(w, r)
+	}))
+	mux.HandleFunc("/gtfs/shapes/{id}", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleShape(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/routes", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleRoutes(w, r)
+	}))
+	mux.HandleFunc("/gtfs/routes/{id}", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleRoute(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/routes/{id}/shapes", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleRouteShapes(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/stops/nearby", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleNearbyStops(w, r)
+	}))
+	mux.HandleFunc("/gtfs/stops/search", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleStopSearch(w, r)
+	}))
+	mux.HandleFunc("/gtfs/stops/{id}/departures", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleStopDepartures(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/stops/{id}/transfers", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleStopTransfers(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/stations/{id}", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleStation(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/fares/estimate", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleFareEstimate(w, r)
+	}))
+	mux.HandleFunc("/gtfs/plan", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandlePlan(w, r)
+	}))
+	mux.HandleFunc("/gtfs/trips/{id}", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		handlers.HandleTrip(w, r, id)
+	}))
+	mux.HandleFunc("/gtfs/admin/validate", services.LoggerMiddleware(services.VerifyJWT(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleValidateFeed(w, r)
+	})))
+	mux.HandleFunc("/gtfs/admin/reload", services.LoggerMiddleware(services.VerifyJWT(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleReloadFeed(w, r)
+	})))
+	mux.HandleFunc("/health", services.LoggerMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleHealth(w, r)
+	}))
 }
@@ -21,7 +21,13 @@ type Chat struct {
 	Messages []string `json:"messages"`
 	Active   int64    `json:"active"`
 }
+type FeedHeader struct {
+	GtfsRealtimeVersion string `json:"gtfs_realtime_version"`
+	Incrementality      string `json:"incrementality"` // FULL_DATASET, DIFFERENTIAL
+	Timestamp           int64  `json:"timestamp,omitempty"`
+}
 type Alert struct {
+	ID              string           `json:"id"`
 	ActivePeriods   []ActivePeriod   `json:"active_period,omitempty"`
 	InformedEntity  []EntitySelector `json:"informed_entity,omitempty"`
 	Cause           string           `json:"cause,omitempty"`
@@ -44,6 +50,7 @@ type Translation struct {
 	Language string `json:"language,omitempty"`
 }
 type TripUpdate struct {
+	ID             string            `json:"id"`
 	Trip           TripDescriptor    `json:"trip"`
 	Vehicle        VehicleDescriptor `json:"vehicle,omitempty"`
 	StopTimeUpdate []StopTimeUpdate  `json:"stop_time_update,omitempty"`
@@ -52,7 +59,9 @@ type TripUpdate struct {
 type TripDescriptor struct {
 	TripID               string `json:"trip_id,omitempty"`
 	RouteID              string `json:"route_id,omitempty"`
-	DirectionID          int    `json:"direction_id,omitempty"`
+	DirectionID          *int   `json:"direction_id,omitempty"`
+	StartDate            string `json:"start_date,omitempty"`
+	StartTime            string `json:"start_time,omitempty"`
 	ScheduleRelationship string `json:"schedule_relationship,omitempty"` // SCHEDULED, ADDED, CANCELED
 }
 type StopTimeUpdate struct {
@@ -63,22 +72,25 @@ type StopTimeUpdate struct {
 	ScheduleRelationship string        `json:"schedule_relationship,omitempty"` // SCHEDULED, SKIPPED
 }
 type StopTimeEvent struct {
-	Time int64 `json:"time,omitempty"`
+	Time  int64  `json:"time,omitempty"`
+	Delay *int32 `json:"delay,omitempty"` // seconds, set only when the feed gives one
 }
 type VehiclePosition struct {
-	Trip          TripDescriptor    `json:"trip,omitempty"`
-	Vehicle       VehicleDescriptor `json:"vehicle,omitempty"`
-	Position      Position          `json:"position,omitempty"`
-	StopID        string            `json:"stop_id,omitempty"`
-	CurrentStatus int               `json:"current_status,omitempty"` // 0 = INCOMING_AT, 1 = STOPPED_AT, 2 = IN_TRANSIT_TO
-	Timestamp     int64             `json:"timestamp,omitempty"`
+	ID              string            `json:"id"`
+	Trip            TripDescriptor    `json:"trip,omitempty"`
+	Vehicle         VehicleDescriptor `json:"vehicle,omitempty"`
+	Position        *Position         `json:"position,omitempty"`
+	StopID          string            `json:"stop_id,omitempty"`
+	CurrentStatus   string            `json:"current_status,omitempty"` // INCOMING_AT, STOPPED_AT, IN_TRANSIT_TO
+	Timestamp       int64             `json:"timestamp,omitempty"`
+	OccupancyStatus string            `json:"occupancy_status,omitempty"` // EMPTY, MANY_SEATS_AVAILABLE, ...
 }
 type VehicleDescriptor struct {
 	ID    string `json:"id,omitempty"`
 	Label string `json:"label,omitempty"`
 }
 type Position struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Bearing   float64 `json:"bearing,omitempty"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Bearing   *float64 `json:"bearing,omitempty"`
 }
@@ -0,0 +1,265 @@
+package transportation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultFetchTimeout   = 10 * time.Second
+	defaultFetchAttempts  = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	// maxFeedSize bounds the decoded size of a GTFS-RT response.
+	maxFeedSize = 64 << 20
+)
+
+// RetryPolicy says how often and how patiently a FeedClient retries. The
+// wait before each retry doubles from InitialBackoff up to MaxBackoff.
+// Network errors, 429 and 5xx responses are retried; a 429 or 503 with a
+// Retry-After header waits as long as it asks, up to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy tries a fetch three times, waiting half a second and
+// then a second between attempts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    defaultFetchAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+	}
+}
+
+// FeedClient fetches one GTFS-RT feed. It sends Headers with every request,
+// such as an API key, asks for gzip, and remembers the ETag and
+// Last-Modified of the last response so an unchanged feed is answered with
+// 304 Not Modified and served from the copy it already has. HTTPClient may
+// be replaced, e.g. to fetch from an httptest server. A FeedClient is safe
+// for concurrent use.
+type FeedClient struct {
+	URL        string
+	Headers    http.Header
+	Timeout    time.Duration
+	Retry      RetryPolicy
+	HTTPClient *http.Client
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	last         *gtfs.FeedMessage
+}
+
+// FeedFetch is a fetched feed with what is known about the fetch. Message
+// may be shared with other fetches of the same client and must not be
+// modified.
+type FeedFetch struct {
+	Message    *gtfs.FeedMessage
+	FetchedAt  time.Time
+	Duration   time.Duration
+	Attempts   int
+	StatusCode int
+	// NotModified is set when the server answered 304 and Message is the
+	// feed from an earlier fetch.
+	NotModified  bool
+	ETag         string
+	LastModified string
+	// Size is the decoded size of the response body in bytes, and
+	// Compressed tells whether it was sent gzipped.
+	Size       int
+	Compressed bool
+}
+
+// NewFeedClient returns a client for the feed at url with the default
+// timeout and retry policy.
+func NewFeedClient(url string, headers http.Header) *FeedClient {
+	return &FeedClient{
+		URL:        url,
+		Headers:    headers,
+		Timeout:    defaultFetchTimeout,
+		Retry:      DefaultRetryPolicy(),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// retryableError is a failed attempt worth repeating, with how long the
+// server asked to wait, if it did.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Fetch fetches and decodes the feed, retrying as the client's policy
+// allows. ctx bounds the whole fetch, retries included, and each attempt
+// is also limited to the client's Timeout.
+func (c *FeedClient) Fetch(ctx context.Context) (*FeedFetch, error) {
+	policy := c.Retry
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+
+	start := time.Now()
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		var fetch *FeedFetch
+		fetch, err = c.fetchOnce(ctx)
+		if err == nil {
+			fetch.Attempts = attempt
+			fetch.FetchedAt = time.Now()
+			fetch.Duration = fetch.FetchedAt.Sub(start)
+			return fetch, nil
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt == policy.MaxAttempts {
+			break
+		}
+		wait := backoff
+		if retryable.retryAfter > 0 {
+			wait = retryable.retryAfter
+		}
+		if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+			wait = policy.MaxBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to fetch GTFS-RT feed: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+	return nil, err
+}
+
+func (c *FeedClient) fetchOnce(ctx context.Context) (*FeedFetch, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	// Asking for gzip ourselves leaves decompression to us, which also
+	// covers servers that send a gzipped file without Content-Encoding.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	c.mu.Lock()
+	cached, etag, lastModified := c.last, c.etag, c.lastModified
+	c.mu.Unlock()
+	if cached != nil {
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("failed to fetch GTFS-RT feed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	fetch := &FeedFetch{
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		fetch.Message = cached
+		fetch.NotModified = true
+		fetch.ETag, fetch.LastModified = etag, lastModified
+		return fetch, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, &retryableError{
+			err:        fmt.Errorf("bad response status: %d", resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("bad response status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("failed to read GTFS-RT data: %w", err)}
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" || bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		fetch.Compressed = true
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress GTFS-RT data: %w", err)
+		}
+		data, err = io.ReadAll(io.LimitReader(reader, maxFeedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress GTFS-RT data: %w", err)
+		}
+	}
+	if len(data) > maxFeedSize {
+		return nil, fmt.Errorf("GTFS-RT feed is larger than %d bytes", maxFeedSize)
+	}
+	fetch.Size = len(data)
+
+	message := &gtfs.FeedMessage{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("failed to parse GTFS-RT feed: %w", err)
+	}
+	fetch.Message = message
+
+	c.mu.Lock()
+	c.last, c.etag, c.lastModified = message, fetch.ETag, fetch.LastModified
+	c.mu.Unlock()
+	return fetch, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning 0 when there is none.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package transportation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordedFeed returns testdata/TripUpdate.pb, an RTD trip updates feed
+// with one trip.
+func recordedFeed(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/TripUpdate.pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testClient returns a client for server that retries without waiting.
+func testClient(server *httptest.Server) *FeedClient {
+	client := NewFeedClient(server.URL, nil)
+	client.HTTPClient = server.Client()
+	client.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return client
+}
+
+func checkRecordedFeed(t *testing.T, fetch *FeedFetch) {
+	t.Helper()
+	if got := fetch.Message.GetHeader().GetTimestamp(); got != 1743462284 {
+		t.Errorf("header timestamp = %d, want 1743462284", got)
+	}
+	entities := fetch.Message.GetEntity()
+	if len(entities) != 1 || entities[0].GetTripUpdate().GetTrip().GetTripId() != "115184047" {
+		t.Errorf("entities = %v, want the trip update of trip 115184047", entities)
+	}
+}
+
+func TestFetchRetriesServerErrors(t *testing.T) {
+	feed := recordedFeed(t)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	fetch, err := testClient(server).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetch.Attempts != 2 || requests.Load() != 2 {
+		t.Errorf("attempts = %d, requests = %d, want 2 and 2", fetch.Attempts, requests.Load())
+	}
+	checkRecordedFeed(t, fetch)
+}
+
+func TestFetchDoesNotRetryClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	if _, err := testClient(server).Fetch(context.Background()); err == nil {
+		t.Fatal("Fetch succeeded, want an error for 404")
+	}
+	if requests.Load() != 1 {
+		t.Errorf("requests = %d, want 1", requests.Load())
+	}
+}
+
+func TestFetchNotModified(t *testing.T) {
+	feed := recordedFeed(t)
+	const etag = `"v1"`
+	const lastModified = "Mon, 31 Mar 2025 23:04:44 GMT"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	client := testClient(server)
+	first, err := client.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NotModified {
+		t.Error("first fetch is NotModified")
+	}
+
+	second, err := client.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.NotModified || second.StatusCode != http.StatusNotModified {
+		t.Errorf("second fetch NotModified = %v, status = %d, want true and 304", second.NotModified, second.StatusCode)
+	}
+	if second.Message != first.Message {
+		t.Error("304 did not return the cached message")
+	}
+	if second.ETag != etag || second.LastModified != lastModified {
+		t.Errorf("ETag = %q, Last-Modified = %q, want those of the cached feed", second.ETag, second.LastModified)
+	}
+}
+
+func TestFetchGzip(t *testing.T) {
+	feed := recordedFeed(t)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(feed)
+	writer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	fetch, err := testClient(server).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fetch.Compressed || fetch.Size != len(feed) {
+		t.Errorf("compressed = %v, size = %d, want true and %d", fetch.Compressed, fetch.Size, len(feed))
+	}
+	checkRecordedFeed(t, fetch)
+}
+
+func TestFetchRejectsOversizedFeeds(t *testing.T) {
+	oversized := make([]byte, maxFeedSize+1)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(oversized)
+	writer.Close()
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"plain", oversized},
+		{"gzip", compressed.Bytes()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(test.body)
+			}))
+			defer server.Close()
+
+			_, err := testClient(server).Fetch(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "larger than") {
+				t.Errorf("err = %v, want the feed rejected as too large", err)
+			}
+		})
+	}
+}
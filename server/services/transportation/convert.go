@@ -0,0 +1,182 @@
+package transportation
+
+import (
+	"strconv"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+
+	"probable-system/main.go/server/services/db"
+)
+
+// The converters below turn a decoded GTFS-RT feed into the JSON types of
+// the db package. They read the feed through the generated getters, so a
+// message the producer left out never panics on a nil pointer. Optional
+// fields whose zero value means something, such as a bearing of 0 or
+// direction 0, are only set when the feed gives them.
+
+// ConvertHeader returns the header of feed.
+func ConvertHeader(feed *gtfs.FeedMessage) db.FeedHeader {
+	header := feed.GetHeader()
+	return db.FeedHeader{
+		GtfsRealtimeVersion: header.GetGtfsRealtimeVersion(),
+		Incrementality:      header.GetIncrementality().String(),
+		Timestamp:           int64(header.GetTimestamp()),
+	}
+}
+
+// ConvertAlerts returns the alerts of feed, with each text in language when
+// the feed translates it, e.g. "en", and otherwise in its first
+// translation.
+func ConvertAlerts(feed *gtfs.FeedMessage, language string) []db.Alert {
+	alerts := []db.Alert{}
+	for _, entity := range feed.GetEntity() {
+		alert := entity.GetAlert()
+		if alert == nil || entity.GetIsDeleted() {
+			continue
+		}
+		converted := db.Alert{
+			ID:              entity.GetId(),
+			Cause:           alert.GetCause().String(),
+			Effect:          alert.GetEffect().String(),
+			HeaderText:      convertTranslation(alert.GetHeaderText(), language),
+			DescriptionText: convertTranslation(alert.GetDescriptionText(), language),
+		}
+		for _, period := range alert.GetActivePeriod() {
+			converted.ActivePeriods = append(converted.ActivePeriods, db.ActivePeriod{
+				Start: int64(period.GetStart()),
+				End:   int64(period.GetEnd()),
+			})
+		}
+		for _, selector := range alert.GetInformedEntity() {
+			converted.InformedEntity = append(converted.InformedEntity, db.EntitySelector{
+				AgencyID:  selector.GetAgencyId(),
+				RouteID:   selector.GetRouteId(),
+				RouteType: int(selector.GetRouteType()),
+				StopID:    selector.GetStopId(),
+			})
+		}
+		alerts = append(alerts, converted)
+	}
+	return alerts
+}
+
+// convertTranslation picks the translation of text in language, falling
+// back to the first one.
+func convertTranslation(text *gtfs.TranslatedString, language string) db.Translation {
+	translations := text.GetTranslation()
+	if len(translations) == 0 {
+		return db.Translation{}
+	}
+	chosen := translations[0]
+	for _, translation := range translations {
+		if language != "" && translation.GetLanguage() == language {
+			chosen = translation
+			break
+		}
+	}
+	return db.Translation{Text: chosen.GetText(), Language: chosen.GetLanguage()}
+}
+
+// ConvertTripUpdates returns the trip updates of feed.
+func ConvertTripUpdates(feed *gtfs.FeedMessage) []db.TripUpdate {
+	updates := []db.TripUpdate{}
+	for _, entity := range feed.GetEntity() {
+		update := entity.GetTripUpdate()
+		if update == nil || entity.GetIsDeleted() {
+			continue
+		}
+		converted := db.TripUpdate{
+			ID:        entity.GetId(),
+			Trip:      convertTrip(update.GetTrip()),
+			Vehicle:   convertVehicle(update.GetVehicle()),
+			Timestamp: int64(update.GetTimestamp()),
+		}
+		for _, stopTimeUpdate := range update.GetStopTimeUpdate() {
+			converted.StopTimeUpdate = append(converted.StopTimeUpdate, db.StopTimeUpdate{
+				StopSequence:         int(stopTimeUpdate.GetStopSequence()),
+				StopID:               stopTimeUpdate.GetStopId(),
+				Arrival:              convertStopTimeEvent(stopTimeUpdate.GetArrival()),
+				Departure:            convertStopTimeEvent(stopTimeUpdate.GetDeparture()),
+				ScheduleRelationship: stopTimeUpdate.GetScheduleRelationship().String(),
+			})
+		}
+		updates = append(updates, converted)
+	}
+	return updates
+}
+
+func convertStopTimeEvent(event *gtfs.TripUpdate_StopTimeEvent) db.StopTimeEvent {
+	converted := db.StopTimeEvent{Time: event.GetTime()}
+	if event != nil && event.Delay != nil {
+		delay := event.GetDelay()
+		converted.Delay = &delay
+	}
+	return converted
+}
+
+// ConvertVehiclePositions returns the vehicle positions of feed.
+func ConvertVehiclePositions(feed *gtfs.FeedMessage) []db.VehiclePosition {
+	positions := []db.VehiclePosition{}
+	for _, entity := range feed.GetEntity() {
+		vehicle := entity.GetVehicle()
+		if vehicle == nil || entity.GetIsDeleted() {
+			continue
+		}
+		converted := db.VehiclePosition{
+			ID:        entity.GetId(),
+			Trip:      convertTrip(vehicle.GetTrip()),
+			Vehicle:   convertVehicle(vehicle.GetVehicle()),
+			StopID:    vehicle.GetStopId(),
+			Timestamp: int64(vehicle.GetTimestamp()),
+		}
+		if position := vehicle.GetPosition(); position != nil {
+			converted.Position = &db.Position{
+				Latitude:  widenFloat(position.GetLatitude()),
+				Longitude: widenFloat(position.GetLongitude()),
+			}
+			if position.Bearing != nil {
+				bearing := widenFloat(position.GetBearing())
+				converted.Position.Bearing = &bearing
+			}
+		}
+		if vehicle.CurrentStatus != nil {
+			converted.CurrentStatus = vehicle.GetCurrentStatus().String()
+		}
+		if vehicle.OccupancyStatus != nil {
+			converted.OccupancyStatus = vehicle.GetOccupancyStatus().String()
+		}
+		positions = append(positions, converted)
+	}
+	return positions
+}
+
+// widenFloat converts a float32 of the feed to the float64 with the same
+// shortest decimal form, so 39.7 is not written as 39.70000076293945.
+func widenFloat(value float32) float64 {
+	widened, _ := strconv.ParseFloat(strconv.FormatFloat(float64(value), 'g', -1, 32), 64)
+	return widened
+}
+
+func convertTrip(trip *gtfs.TripDescriptor) db.TripDescriptor {
+	converted := db.TripDescriptor{
+		TripID:    trip.GetTripId(),
+		RouteID:   trip.GetRouteId(),
+		StartDate: trip.GetStartDate(),
+		StartTime: trip.GetStartTime(),
+	}
+	if trip != nil {
+		converted.ScheduleRelationship = trip.GetScheduleRelationship().String()
+	}
+	if trip != nil && trip.DirectionId != nil {
+		direction := int(trip.GetDirectionId())
+		converted.DirectionID = &direction
+	}
+	return converted
+}
+
+func convertVehicle(vehicle *gtfs.VehicleDescriptor) db.VehicleDescriptor {
+	return db.VehicleDescriptor{
+		ID:    vehicle.GetId(),
+		Label: vehicle.GetLabel(),
+	}
+}
@@ -0,0 +1,111 @@
+package transportation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// FeedConfig describes one agency feed: where its static GTFS is read from
+// and where its GTFS-RT feeds are fetched. An empty URL means the agency
+// does not publish that feed.
+type FeedConfig struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	Static              string `json:"static"`
+	Snapshot            string `json:"snapshot"`
+	AlertsURL           string `json:"alerts_url"`
+	TripUpdatesURL      string `json:"trip_updates_url"`
+	VehiclePositionsURL string `json:"vehicle_positions_url"`
+	// Headers are sent with every GTFS-RT request, such as an API key.
+	// Values may refer to environment variables as $NAME or ${NAME}, which
+	// keeps keys out of the config file.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// RealtimeClients returns clients for the feed's alerts, trip updates and
+// vehicle positions feeds, nil for those it does not publish.
+func (c FeedConfig) RealtimeClients() (alerts, tripUpdates, vehiclePositions *FeedClient) {
+	headers := make(http.Header, len(c.Headers))
+	for name, value := range c.Headers {
+		headers.Set(name, os.ExpandEnv(value))
+	}
+	client := func(url string) *FeedClient {
+		if url == "" {
+			return nil
+		}
+		return NewFeedClient(url, headers)
+	}
+	return client(c.AlertsURL), client(c.TripUpdatesURL), client(c.VehiclePositionsURL)
+}
+
+// FeedRegistry is the set of feeds served, read from a JSON config file:
+//
+//	{
+//	  "default": "rtd",
+//	  "feeds": [
+//	    {"id": "rtd", "name": "RTD Denver", "static": "processing/input", ...}
+//	  ]
+//	}
+//
+// Requests that do not name a feed are served from the default feed.
+type FeedRegistry struct {
+	Default string       `json:"default"`
+	Feeds   []FeedConfig `json:"feeds"`
+}
+
+// DefaultFeedRegistry returns a registry holding only the RTD feed, read
+// from staticPath, for running without a config file.
+func DefaultFeedRegistry(staticPath string, snapshotPath string) *FeedRegistry {
+	return &FeedRegistry{
+		Default: "rtd",
+		Feeds: []FeedConfig{{
+			ID:                  "rtd",
+			Name:                "RTD Denver",
+			Static:              staticPath,
+			Snapshot:            snapshotPath,
+			AlertsURL:           rtdAlerts,
+			TripUpdatesURL:      rtdTripUpdates,
+			VehiclePositionsURL: rtdVehiclePosition,
+		}},
+	}
+}
+
+// LoadFeedRegistry reads and checks the feed registry config at path. When
+// the config names no default, the first feed is the default.
+func LoadFeedRegistry(path string) (*FeedRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed config: %w", err)
+	}
+	registry := &FeedRegistry{}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("failed to parse feed config %s: %w", path, err)
+	}
+	if len(registry.Feeds) == 0 {
+		return nil, fmt.Errorf("feed config %s lists no feeds", path)
+	}
+
+	ids := make(map[string]bool)
+	for _, feed := range registry.Feeds {
+		if feed.ID == "" || strings.Contains(feed.ID, ":") {
+			return nil, fmt.Errorf("feed config %s: feed id %q must be non-empty and must not contain ':'", path, feed.ID)
+		}
+		if ids[feed.ID] {
+			return nil, fmt.Errorf("feed config %s: feed id %q appears more than once", path, feed.ID)
+		}
+		ids[feed.ID] = true
+		if feed.Static == "" {
+			return nil, fmt.Errorf("feed config %s: feed %q has no static source", path, feed.ID)
+		}
+	}
+	if registry.Default == "" {
+		registry.Default = registry.Feeds[0].ID
+	}
+	if !ids[registry.Default] {
+		return nil, fmt.Errorf("feed config %s: default feed %q is not listed", path, registry.Default)
+	}
+	return registry, nil
+}
@@ -1,112 +1,5 @@
 package transportation
 
-import (
-	"context"
-	"fmt"
-	"io"
-	"net/http"
-	"time"
-
-	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
-	"google.golang.org/protobuf/proto"
-)
-
 const rtdAlerts = "https://www.rtd-denver.com/files/gtfs-rt/Alerts.pb"
 const rtdTripUpdates = "https://www.rtd-denver.com/files/gtfs-rt/TripUpdate.pb"
 const rtdVehiclePosition = "https://www.rtd-denver.com/files/gtfs-rt/VehiclePosition.pb"
-
-func FetchAlerts() (*gtfs.FeedMessage, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rtdAlerts, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch GTFS-RT feed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response status: %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read GTFS-RT data: %w", err)
-	}
-
-	feed := &gtfs.FeedMessage{}
-	if err := proto.Unmarshal(data, feed); err != nil {
-		return nil, fmt.Errorf("failed to parse GTFS-RT feed: %w", err)
-	}
-
-	return feed, nil
-}
-
-func FetchTripUpdates() (*gtfs.FeedMessage, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rtdTripUpdates, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch GTFS-RT feed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response status: %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read GTFS-RT data: %w", err)
-	}
-
-	feed := &gtfs.FeedMessage{}
-	if err := proto.Unmarshal(data, feed); err != nil {
-		return nil, fmt.Errorf("failed to parse GTFS-RT feed: %w", err)
-	}
-
-	return feed, nil
-}
-
-func FetchVehiclePosition() (*gtfs.FeedMessage, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rtdVehiclePosition, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch GTFS-RT feed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad response status: %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read GTFS-RT data: %w", err)
-	}
-
-	feed := &gtfs.FeedMessage{}
-	if err := proto.Unmarshal(data, feed); err != nil {
-		return nil, fmt.Errorf("failed to parse GTFS-RT feed: %w", err)
-	}
-
-	return feed, nil
-}
@@ -0,0 +1,126 @@
+package transportation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+)
+
+// FeedSnapshot is the latest copy of a GTFS-RT feed a FeedPoller holds.
+// When a poll fails the previous message is kept, with the error and the
+// number of failures in a row, so readers keep being served the last good
+// feed. Message is shared by every reader and must not be modified.
+type FeedSnapshot struct {
+	Message *gtfs.FeedMessage
+	// Timestamp is the feed header timestamp, when the producer wrote it,
+	// and zero when the header has none.
+	Timestamp time.Time
+	// FetchedAt is when Message was last fetched or confirmed unchanged,
+	// and LastAttempt when the last poll finished, successfully or not.
+	FetchedAt   time.Time
+	LastAttempt time.Time
+	LastError   string
+	Failures    int
+	// Stale and Age are filled in by FeedPoller.Latest when the snapshot
+	// is read: Age is the time since FetchedAt, and Stale is set when the
+	// last poll failed or the snapshot has not been refreshed for two
+	// polling intervals.
+	Stale bool
+	Age   time.Duration
+}
+
+// FeedPoller keeps a snapshot of one GTFS-RT feed, refreshed by a
+// background poll every interval once started, so any number of requests
+// cost one upstream fetch per interval. A poller that is not started
+// fetches when it is read, and concurrent readers share that fetch.
+type FeedPoller struct {
+	Name   string
+	Client *FeedClient
+
+	interval  time.Duration
+	running   atomic.Bool
+	snapshot  atomic.Pointer[FeedSnapshot]
+	refreshMu sync.Mutex
+}
+
+// NewFeedPoller returns a poller for client, named in log messages by name.
+func NewFeedPoller(name string, client *FeedClient) *FeedPoller {
+	return &FeedPoller{Name: name, Client: client}
+}
+
+// Start polls the feed now and then every interval in the background. It
+// must be called at most once, before the poller is read.
+func (p *FeedPoller) Start(interval time.Duration) {
+	p.interval = interval
+	p.running.Store(true)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.refresh(context.Background(), time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// Latest returns the current snapshot of the feed. A started poller that
+// has not finished its first poll waits for it; one that is not started
+// fetches the feed on every call. It only fails when no copy of the feed
+// has ever been fetched.
+func (p *FeedPoller) Latest(ctx context.Context) (FeedSnapshot, error) {
+	snapshot := p.snapshot.Load()
+	if snapshot == nil || !p.running.Load() {
+		snapshot = p.refresh(ctx, time.Now())
+	}
+	if snapshot == nil {
+		return FeedSnapshot{}, fmt.Errorf("failed to fetch GTFS-RT feed: %w", ctx.Err())
+	}
+	if snapshot.Message == nil {
+		return FeedSnapshot{}, errors.New(snapshot.LastError)
+	}
+
+	latest := *snapshot
+	latest.Age = time.Since(latest.FetchedAt)
+	latest.Stale = latest.LastError != "" || (p.running.Load() && latest.Age > 2*p.interval)
+	return latest, nil
+}
+
+// refresh polls the feed unless a poll finished after since, in which case
+// callers that queued up behind it take its result. A poll abandoned
+// because ctx ended is not recorded.
+func (p *FeedPoller) refresh(ctx context.Context, since time.Time) *FeedSnapshot {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	previous := p.snapshot.Load()
+	if previous != nil && !previous.LastAttempt.Before(since) {
+		return previous
+	}
+
+	fetch, err := p.Client.Fetch(ctx)
+	next := &FeedSnapshot{LastAttempt: time.Now()}
+	if err != nil {
+		if ctx.Err() != nil {
+			return previous
+		}
+		fmt.Printf("Error polling GTFS-RT feed %s: %v\n", p.Name, err)
+		if previous != nil {
+			next.Message, next.Timestamp, next.FetchedAt = previous.Message, previous.Timestamp, previous.FetchedAt
+			next.Failures = previous.Failures
+		}
+		next.LastError = err.Error()
+		next.Failures++
+	} else {
+		next.Message = fetch.Message
+		if timestamp := fetch.Message.GetHeader().GetTimestamp(); timestamp != 0 {
+			next.Timestamp = time.Unix(int64(timestamp), 0)
+		}
+		next.FetchedAt = fetch.FetchedAt
+	}
+	p.snapshot.Store(next)
+	return next
+}
@@ -0,0 +1,172 @@
+package transportation
+
+import (
+	"time"
+
+	"probable-system/main.go/processing"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+)
+
+// TripUpdates holds the trip updates of a GTFS-RT feed by trip ID. A trip
+// may have several updates when it runs more than once, told apart by their
+// start date.
+type TripUpdates map[string][]*gtfs.TripUpdate
+
+// IndexTripUpdates collects the trip updates of feed by trip ID. An update
+// with a start time is also indexed under the ID of the frequencies.txt run
+// starting then. A nil feed gives an empty index.
+func IndexTripUpdates(feed *gtfs.FeedMessage) TripUpdates {
+	updates := make(TripUpdates)
+	if feed == nil {
+		return updates
+	}
+	for _, entity := range feed.GetEntity() {
+		update := entity.GetTripUpdate()
+		if update == nil || entity.GetIsDeleted() {
+			continue
+		}
+		tripId := update.GetTrip().GetTripId()
+		if tripId == "" {
+			continue
+		}
+		updates[tripId] = append(updates[tripId], update)
+		if startTime, err := processing.ParseTime(update.GetTrip().GetStartTime()); err == nil && startTime.IsSet() {
+			runId := processing.FrequencyRunID(tripId, startTime)
+			updates[runId] = append(updates[runId], update)
+		}
+	}
+	return updates
+}
+
+// Find returns the update for a trip on a service date in YYYYMMDD form. An
+// update without a start date refers to the trip's current run, so it only
+// matches when serviceDate is currentDate, the service date of the run
+// nearest to now.
+func (u TripUpdates) Find(tripId string, serviceDate string, currentDate string) (*gtfs.TripUpdate, bool) {
+	for _, update := range u[tripId] {
+		startDate := update.GetTrip().GetStartDate()
+		if startDate == "" {
+			startDate = currentDate
+		}
+		if startDate == serviceDate {
+			return update, true
+		}
+	}
+	return nil, false
+}
+
+// Prediction is the realtime estimate for one stop time of a trip.
+type Prediction struct {
+	// Departure is the predicted departure, or arrival when the feed gives
+	// no departure. It is zero when there is no estimate.
+	Departure time.Time
+	Delay     time.Duration
+	Canceled  bool
+	Skipped   bool
+}
+
+// HasEstimate reports whether the prediction carries a predicted time.
+func (p Prediction) HasEstimate() bool {
+	return !p.Departure.IsZero()
+}
+
+// Predict estimates the departure at stopTimes[index] of a trip running on
+// serviceDate in loc, from the trip's update. stopTimes are the trip's stop
+// times in stop_sequence order. As the GTFS-RT spec describes, a stop without
+// its own update takes the delay of the nearest update before it, or the
+// trip's delay when there is none; a NO_DATA update stops that propagation.
+func Predict(update *gtfs.TripUpdate, stopTimes []processing.StopTime, index int, serviceDate time.Time, loc *time.Location) Prediction {
+	var prediction Prediction
+	if update == nil || index < 0 || index >= len(stopTimes) {
+		return prediction
+	}
+	if update.GetTrip().GetScheduleRelationship() == gtfs.TripDescriptor_CANCELED {
+		prediction.Canceled = true
+		return prediction
+	}
+
+	scheduled := stopTimes[index].DepartureTime.On(serviceDate, loc)
+	delay, found := tripDelay(update)
+
+	matched := 0
+	for _, stopTimeUpdate := range update.GetStopTimeUpdate() {
+		position, ok := matchStopTimeUpdate(stopTimeUpdate, stopTimes, matched)
+		if !ok {
+			continue
+		}
+		matched = position
+		if position > index {
+			break
+		}
+
+		relationship := stopTimeUpdate.GetScheduleRelationship()
+		if position == index && relationship == gtfs.TripUpdate_StopTimeUpdate_SKIPPED {
+			prediction.Skipped = true
+			return prediction
+		}
+		if relationship == gtfs.TripUpdate_StopTimeUpdate_NO_DATA {
+			found = false
+			continue
+		}
+		if relationship == gtfs.TripUpdate_StopTimeUpdate_SKIPPED {
+			continue
+		}
+
+		event := stopTimeUpdate.GetDeparture()
+		eventScheduled := stopTimes[position].DepartureTime
+		if event == nil || (event.Time == nil && event.Delay == nil) {
+			event = stopTimeUpdate.GetArrival()
+			eventScheduled = stopTimes[position].ArrivalTime
+		}
+		switch {
+		case event == nil:
+			continue
+		case event.Delay != nil:
+			delay, found = time.Duration(event.GetDelay())*time.Second, true
+		case event.Time != nil && eventScheduled.IsSet():
+			delay = time.Unix(event.GetTime(), 0).Sub(eventScheduled.On(serviceDate, loc))
+			found = true
+		}
+		if position == index && event.Time != nil {
+			prediction.Departure = time.Unix(event.GetTime(), 0).In(loc)
+			prediction.Delay = prediction.Departure.Sub(scheduled)
+			return prediction
+		}
+	}
+
+	if found {
+		prediction.Delay = delay
+		prediction.Departure = scheduled.Add(delay)
+	}
+	return prediction
+}
+
+func tripDelay(update *gtfs.TripUpdate) (time.Duration, bool) {
+	if update.Delay == nil {
+		return 0, false
+	}
+	return time.Duration(update.GetDelay()) * time.Second, true
+}
+
+// matchStopTimeUpdate finds the stop time a stop time update refers to, by
+// stop_sequence or else by the first matching stop_id from position from
+// on, since updates are listed in trip order.
+func matchStopTimeUpdate(stopTimeUpdate *gtfs.TripUpdate_StopTimeUpdate, stopTimes []processing.StopTime, from int) (int, bool) {
+	if stopTimeUpdate.StopSequence != nil {
+		sequence := int(stopTimeUpdate.GetStopSequence())
+		for i, stopTime := range stopTimes {
+			if stopTime.StopSequence == sequence {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	stopId := stopTimeUpdate.GetStopId()
+	for i := from; i < len(stopTimes); i++ {
+		if stopTimes[i].StopID == stopId {
+			return i, true
+		}
+	}
+	return 0, false
+}